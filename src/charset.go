@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// Кодировка по умолчанию для документов без указания кодировки.
+// См.: https://html.spec.whatwg.org/multipage/parsing.html#determining-the-character-encoding
+const DEFAULT_CHARSET = "windows-1252"
+
+// Правило @charset в начале CSS: @charset "windows-1251";
+var cssCharset = regexp.MustCompile(`^@charset "([^"]*)";`)
+
+// Объявление кодировки в теге <meta>:
+// <meta charset="windows-1251">
+// <meta http-equiv="Content-Type" content="text/html; charset=windows-1251">
+var metaCharset = regexp.MustCompile(`(?i)(<meta\s[^>]*?charset\s*=\s*["']?)([^"'\s;/>]*)`)
+
+// Открывающий тег <head>:
+var headTag = regexp.MustCompile(`(?i)<head(\s[^>]*)?>`)
+
+// Метки порядка байтов:
+var boms = []struct {
+	bom   []byte
+	label string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+}
+
+// Проверить, является ли ресурс текстом, который нужно перекодировать
+// для анализа. XML документы сами объявляют свою кодировку и
+// перекодируются при разборе, см.: newXMLDecoder()
+func isText(obj *Source, mim string) bool {
+	obj.mu.RLock()
+	typ := strings.ToLower(obj.header.Get("Content-Type"))
+	obj.mu.RUnlock()
+
+	if strings.Contains(typ, "xml") || strings.Contains(mim, "xml") {
+		return false
+	}
+	return strings.HasPrefix(mim, "text/") || isScript(obj)
+}
+
+// Определить кодировку текстового ресурса.
+// Возвращает каноническое имя кодировки: "utf-8", "windows-1251", ...
+//
+// Порядок определения: метка порядка байтов, параметр charset в
+// заголовке Content-Type, объявление в документе: <meta charset> в первых
+// 1024 байтах HTML или @charset в CSS. Если кодировка не объявлена,
+// корректный UTF-8 считается UTF-8, иначе используется кодировка
+// ссылающейся страницы или кодировка по умолчанию: ScannerParams.Charset
+func (s *Scanner) detectCharset(obj *Source, mim string, body []byte) string {
+	for _, v := range boms {
+		if bytes.HasPrefix(body, v.bom) {
+			return v.label
+		}
+	}
+
+	obj.mu.RLock()
+	typ := obj.header.Get("Content-Type")
+	obj.mu.RUnlock()
+	if _, params, err := mime.ParseMediaType(typ); err == nil {
+		if _, name := charset.Lookup(params["charset"]); name != "" {
+			return name
+		}
+	}
+
+	// Объявление в документе:
+	var label string
+	if strings.Contains(mim, "text/html") {
+		label = prescanCharset(body)
+	} else if m := cssCharset.FindSubmatch(body); m != nil {
+		label = string(m[1])
+	}
+	if _, name := charset.Lookup(label); name != "" {
+		// Документ не может объявить о себе UTF-16, он уже прочитан как ASCII:
+		if strings.HasPrefix(name, "utf-16") {
+			return "utf-8"
+		}
+		return name
+	}
+
+	if utf8.Valid(body) {
+		return "utf-8"
+	}
+	if name := obj.referrerCharset(); name != "" {
+		return name
+	}
+	if _, name := charset.Lookup(s.params.Charset); name != "" {
+		return name
+	}
+	return DEFAULT_CHARSET
+}
+
+// Найти объявление кодировки в теге <meta> среди первых 1024 байт HTML.
+// Возвращает метку кодировки как она указана в документе или пустую строку.
+func prescanCharset(body []byte) string {
+	if len(body) > 1024 {
+		body = body[:1024]
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data != "meta" {
+				continue
+			}
+			var equiv bool
+			var content string
+			for _, a := range t.Attr {
+				switch a.Key {
+				case "charset":
+					return a.Val
+				case "http-equiv":
+					equiv = strings.EqualFold(a.Val, "content-type")
+				case "content":
+					content = a.Val
+				}
+			}
+			if equiv {
+				if _, params, err := mime.ParseMediaType(content); err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+		}
+	}
+}
+
+// Кодировка страницы, в которой впервые найдена ссылка на ресурс.
+// Стили и скрипты без объявленной кодировки читаются в кодировке
+// подключившей их страницы.
+func (s *Source) referrerCharset() string {
+	s.list.mu.RLock()
+	defer s.list.mu.RUnlock()
+
+	if len(s.in) == 0 {
+		return ""
+	}
+	from := s.in[0].From
+	from.mu.RLock()
+	defer from.mu.RUnlock()
+	return from.charset
+}
+
+// Перекодировать текстовый ресурс в UTF-8 для анализа.
+// Определённая кодировка запоминается в ресурсе. Нетекстовые
+// ресурсы и ресурсы в UTF-8 возвращаются без изменений.
+func (s *Scanner) decodeText(obj *Source, mim string, body []byte) []byte {
+	if !isText(obj, mim) {
+		return body
+	}
+
+	name := s.detectCharset(obj, mim, body)
+	obj.mu.Lock()
+	obj.charset = name
+	obj.mu.Unlock()
+	if name == "utf-8" {
+		return body
+	}
+
+	enc, _ := charset.Lookup(name)
+	text, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		s.logSource(slog.LevelWarn, obj, "Не удалось перекодировать текст в UTF-8", "charset", name, "err", err)
+		return body
+	}
+	return bytes.TrimPrefix(text, boms[0].bom)
+}
+
+// Подготовить перекодированный в UTF-8 текст для сохранения:
+// объявление кодировки в <meta> или @charset заменяется на UTF-8.
+// В HTML без объявления кодировки добавляется <meta charset="utf-8">,
+// иначе браузер откроет сохранённый файл в кодировке по умолчанию.
+// Размер, хеш и кодировка в заголовке Content-Type ресурса обновляются.
+func (s *Scanner) transcode(obj *Source, mim string, text []byte) []byte {
+	switch {
+	case strings.Contains(mim, "text/html"):
+		text = fixMetaCharset(text)
+	case cssCharset.Match(text):
+		text = cssCharset.ReplaceAll(text, []byte(`@charset "utf-8";`))
+	}
+
+	obj.mu.Lock()
+	obj.size = int64(len(text))
+	obj.hash = fmt.Sprintf("%x", sha256.Sum256(text))
+	setHeaderCharset(obj.header, "utf-8")
+	obj.mu.Unlock()
+	return text
+}
+
+// Заменить кодировку в заголовке Content-Type сохраняемого ресурса:
+// "text/html; charset=windows-1251" -> "text/html; charset=utf-8".
+// Заголовок пишется в манифест, и по нему отдаёт файл Server: браузер
+// доверяет заголовку больше, чем <meta>. Без заголовка ничего не делает.
+func setHeaderCharset(h http.Header, name string) {
+	typ := h.Get("Content-Type")
+	if typ == "" {
+		return
+	}
+	mt, params, err := mime.ParseMediaType(typ)
+	if err != nil {
+		mt, _, _ = strings.Cut(typ, ";")
+		mt = strings.ToLower(strings.TrimSpace(mt))
+		params = nil
+	}
+	if params == nil {
+		params = make(map[string]string)
+	}
+	params["charset"] = name
+	h.Set("Content-Type", mime.FormatMediaType(mt, params))
+}
+
+// Заменить объявление кодировки HTML документа на UTF-8.
+// Если кодировка не объявлена, тег <meta charset="utf-8"> добавляется
+// в начало <head>.
+func fixMetaCharset(text []byte) []byte {
+	if metaCharset.Match(text) {
+		return metaCharset.ReplaceAll(text, []byte("${1}utf-8"))
+	}
+	if loc := headTag.FindIndex(text); loc != nil {
+		res := make([]byte, 0, len(text)+24)
+		res = append(res, text[:loc[1]]...)
+		res = append(res, `<meta charset="utf-8">`...)
+		return append(res, text[loc[1]:]...)
+	}
+	return text
+}
+
+// Процентное кодирование строки запроса ссылки, найденной в HTML.
+//
+// По стандарту URL символы вне ASCII в строке запроса кодируются
+// в кодировке страницы, а не в UTF-8: ссылка "?q=привет" на странице
+// в windows-1251 запрашивается как "?q=%EF%F0%E8%E2%E5%F2". Символы,
+// которых нет в кодировке, записываются как HTML сущности: "&#8364;".
+// Путь всегда кодируется в UTF-8, это делает url.URL.
+// См.: https://url.spec.whatwg.org/#query-state
+func encodeQuery(query string, name string) string {
+	var enc *encoding.Encoder
+	if name != "" && name != "utf-8" && !strings.HasPrefix(name, "utf-16") {
+		if e, _ := charset.Lookup(name); e != nil {
+			enc = e.NewEncoder()
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		c := query[i]
+		if c < utf8.RuneSelf {
+			switch c {
+			case ' ', '"', '<', '>':
+				fmt.Fprintf(&b, "%%%02X", c)
+			default:
+				b.WriteByte(c)
+			}
+			i++
+			continue
+		}
+
+		_, n := utf8.DecodeRuneInString(query[i:])
+		v := []byte(query[i : i+n])
+		if enc != nil {
+			if e, err := enc.Bytes(v); err == nil {
+				v = e
+			}
+		}
+		for _, c := range v {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+		i += n
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestTranscodeContentType(t *testing.T) {
+	page, _ := charmap.Windows1251.NewEncoder().String(`<!DOCTYPE html><html><head><meta charset="windows-1251"><title>Привет</title></head><body>мир</body></html>`)
+	f := NewMemoryFetcher().Add("http://site.test/", "text/html; charset=windows-1251", page)
+
+	s := runScanner(t, ScannerParams{URL: "http://site.test/", Transcode: true, Fetchers: map[string]Fetcher{"http": f}})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+	obj := findSource(t, s, "http://site.test/")
+	if v := obj.Header().Get("Content-Type"); v != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", v)
+	}
+	if v := obj.ManifestEntry().ContentType; v != "text/html; charset=utf-8" {
+		t.Errorf("ManifestEntry().ContentType = %q", v)
+	}
+
+	// Копия сайта отдаётся в UTF-8 с верным заголовком:
+	srv, err := NewServer(s.Dir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	if v := w.Result().Header.Get("Content-Type"); v != "text/html; charset=utf-8" {
+		t.Errorf("serve: Content-Type = %q", v)
+	}
+	if !strings.Contains(string(body), "Привет") || !strings.Contains(string(body), `charset="utf-8"`) {
+		t.Errorf("serve: %q", body)
+	}
+}
+
+func TestSetHeaderCharset(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"text/html; charset=windows-1251", "text/html; charset=utf-8"},
+		{"text/css", "text/css; charset=utf-8"},
+		{`TEXT/HTML; Charset="koi8-r"; foo=bar`, "text/html; charset=utf-8; foo=bar"},
+		{"text/html; charset=", "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		h := make(http.Header)
+		if tt.in != "" {
+			h.Set("Content-Type", tt.in)
+		}
+		setHeaderCharset(h, "utf-8")
+		if got := h.Get("Content-Type"); got != tt.want {
+			t.Errorf("setHeaderCharset(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
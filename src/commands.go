@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+)
+
+// Команды программы: GoMirror <команда> [параметры]
+// Без команды программа запускает копирование сайта.
+var commands = map[string]func(args []string) int{
+	"serve":  cmdServe,
+	"verify": cmdVerify,
+}
+
+// Команда serve: просмотр копии сайта через локальный HTTP сервер.
+//
+//	GoMirror serve [-addr 127.0.0.1:8080] [-manifest <файл>] <папка или zip>
+func cmdServe(args []string) int {
+	f := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := f.String("addr", DEFAULT_SERVE_ADDR, "Адрес локального сервера")
+	manifest := f.String("manifest", "", "Путь к манифесту (По умолчанию: <папка>"+MANIFEST_EXT+")")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Использование: "+APP_NAME+" serve [параметры] <папка или zip архив сайта>")
+		f.PrintDefaults()
+	}
+	if err := f.Parse(args); err != nil {
+		return 2
+	}
+	if f.NArg() != 1 {
+		f.Usage()
+		return 2
+	}
+
+	srv, err := NewServer(f.Arg(0), *manifest)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer srv.Close()
+
+	if srv.Manifest() == nil {
+		fmt.Println("Манифест не найден, файлы отдаются по пути без исходных заголовков")
+	}
+	fmt.Println("Копия сайта доступна по адресу: http://" + *addr + "/")
+	fmt.Println("Для остановки нажмите Ctrl+C")
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	return 0
+}
+
+// Команда verify: проверка копии сайта на полноту.
+// Возвращает код 1, если найдены проблемы.
+//
+//	GoMirror verify [-url <адрес>] [-manifest <файл>] [-json] <папка или zip>
+func cmdVerify(args []string) int {
+	f := flag.NewFlagSet("verify", flag.ContinueOnError)
+	site := f.String("url", "", "Адрес исходного сайта (По умолчанию: из манифеста)")
+	manifest := f.String("manifest", "", "Путь к манифесту (По умолчанию: <папка>"+MANIFEST_EXT+")")
+	asJSON := f.Bool("json", false, "Вывести отчёт в формате JSON")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Использование: "+APP_NAME+" verify [параметры] <папка или zip архив сайта>")
+		f.PrintDefaults()
+	}
+	if err := f.Parse(args); err != nil {
+		return 2
+	}
+	if f.NArg() != 1 {
+		f.Usage()
+		return 2
+	}
+
+	r, err := Verify(f.Arg(0), *manifest, *site)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(r, "", "\t")
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(r.String())
+	}
+
+	if len(r.Issues) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Поддерживаемые сканером алгоритмы сжатия.
+// Значение передаётся серверу в заголовке Accept-Encoding.
+const ACCEPT_ENCODING = "gzip, deflate, br, zstd"
+
+// Максимальный размер сжатого тела ответа после распаковки, байт.
+// Защита от "zip бомб": маленьких ответов, распаковка которых
+// занимает всю память.
+const DECODED_BODY_MAX = 512 << 20
+
+// Ошибка распаковки тела, которую не исправит повтор запроса.
+// См.: isPermanentDecodeError()
+var (
+	errEncodingUnsupported = errors.New("Неподдерживаемая кодировка сжатия")
+	errDecodedBodyTooLarge = fmt.Errorf("Размер распакованного тела превышает %v байт", DECODED_BODY_MAX)
+)
+
+// Проверить, что ошибка распаковки повторится при повторном запросе.
+func isPermanentDecodeError(err error) bool {
+	return errors.Is(err, errEncodingUnsupported) || errors.Is(err, errDecodedBodyTooLarge)
+}
+
+// Поток с ограничением размера: при превышении
+// возвращает ошибку, а не обрезает данные.
+type limitReader struct {
+	r io.Reader
+	n int64 // Осталось байт до превышения размера
+}
+
+// Прочитать данные из исходного потока с учётом ограничения.
+// Читается на байт больше остатка, чтобы заметить превышение.
+func (l *limitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		n, l.n = int(l.n), 0
+		return n, errDecodedBodyTooLarge
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// Счётчик байт, прочитанных из потока.
+// Используется для подсчёта объёма данных, переданных по сети.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+// Прочитать данные из исходного потока с подсчётом байт.
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Обернуть тело ответа в декодеры согласно заголовку Content-Encoding.
+//
+// Сервер может применить несколько кодировок подряд, например:
+// "deflate, gzip" - в этом случае они снимаются в обратном порядке.
+// Размер раскодированных данных ограничен: DECODED_BODY_MAX.
+// Возвращает поток с раскодированными данными и функцию для
+// освобождения ресурсов декодеров. Функция никогда не равна nil.
+func decodeBody(r io.Reader, encoding string) (io.Reader, func(), error) {
+	var closers []io.Closer
+	var release = func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}
+
+	decoded := false
+	list := strings.Split(encoding, ",")
+	for i := len(list) - 1; i >= 0; i-- {
+		switch enc := strings.ToLower(strings.TrimSpace(list[i])); enc {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			z, err := gzip.NewReader(r)
+			if err != nil {
+				release()
+				return nil, func() {}, fmt.Errorf("Ошибка чтения gzip потока: %w", err)
+			}
+			closers = append(closers, z)
+			r = z
+		case "deflate":
+			z, err := newDeflateReader(r)
+			if err != nil {
+				release()
+				return nil, func() {}, fmt.Errorf("Ошибка чтения deflate потока: %w", err)
+			}
+			closers = append(closers, z)
+			r = z
+		case "br":
+			r = brotli.NewReader(r)
+		case "zstd":
+			z, err := zstd.NewReader(r)
+			if err != nil {
+				release()
+				return nil, func() {}, fmt.Errorf("Ошибка чтения zstd потока: %w", err)
+			}
+			closers = append(closers, z.IOReadCloser())
+			r = z
+		default:
+			release()
+			return nil, func() {}, fmt.Errorf("%w: \"%v\"", errEncodingUnsupported, enc)
+		}
+		decoded = true
+	}
+
+	if decoded {
+		r = &limitReader{r: r, n: DECODED_BODY_MAX}
+	}
+	return r, release, nil
+}
+
+// Создать декодер для кодировки "deflate".
+//
+// По RFC 9110 это zlib поток, но часть серверов отдаёт "сырой"
+// deflate без заголовка. Формат определяется по первым двум байтам.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// Заголовок zlib: метод сжатия 8 и контрольная сумма по модулю 31:
+	if len(head) == 2 && head[0]&0x0F == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	var z bytes.Buffer
+	w := gzip.NewWriter(&z)
+	w.Write([]byte("hello"))
+	w.Close()
+
+	r, release, err := decodeBody(bytes.NewReader(z.Bytes()), "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	release()
+	if err != nil || string(data) != "hello" {
+		t.Errorf("gzip: %q %v", data, err)
+	}
+
+	_, release, err = decodeBody(strings.NewReader("x"), "gzip, compress")
+	release()
+	if !errors.Is(err, errEncodingUnsupported) || !isPermanentDecodeError(err) {
+		t.Errorf("неподдерживаемая кодировка: %v", err)
+	}
+}
+
+func TestLimitReader(t *testing.T) {
+	for _, tt := range []struct {
+		data  string
+		limit int64
+		err   error
+	}{
+		{"", 0, nil},
+		{"abc", 3, nil},
+		{"abc", 5, nil},
+		{"abcd", 3, errDecodedBodyTooLarge},
+		{"a", 0, errDecodedBodyTooLarge},
+	} {
+		data, err := io.ReadAll(&limitReader{r: strings.NewReader(tt.data), n: tt.limit})
+		if err != tt.err || int64(len(data)) > tt.limit {
+			t.Errorf("%q с лимитом %v: %q %v, want %v", tt.data, tt.limit, data, err, tt.err)
+		}
+	}
+}
+
+func TestScanUnsupportedEncoding(t *testing.T) {
+	h := make(http.Header)
+	h.Set("Content-Type", "text/css")
+	h.Set("Content-Encoding", "compress")
+	f := NewMemoryFetcher().
+		Add("http://mem.test/", "text/html", `<!DOCTYPE html><link rel="stylesheet" href="/s.css">`).
+		Set("http://mem.test/s.css", &MemoryFile{Header: h, Body: []byte("\x1f\x9d")})
+
+	s := runScanner(t, ScannerParams{
+		URL:        "http://mem.test/",
+		RepeatsMax: 3,
+		Fetchers:   map[string]Fetcher{"http": f},
+	})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+
+	// Ошибка не исправится повтором запроса:
+	obj := findSource(t, s, "http://mem.test/s.css")
+	if obj.State() != SourceDownloadError || !errors.Is(obj.Err(), errEncodingUnsupported) {
+		t.Errorf("неподдерживаемая кодировка: %v %v", obj.State(), obj.Err())
+	}
+	if n := countRequests(f, "GET http://mem.test/s.css"); n != 1 {
+		t.Errorf("запросов: %v, want 1", n)
+	}
+}
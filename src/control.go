@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Остановить сканирование.
+//
+// Новые запросы не выполняются, текущие запросы прерываются,
+// уже начатая запись файлов завершается. Необработанные ресурсы
+// получают статус SourceCanceled. После завершения всех потоков
+// сканер переходит в состояние ScannerStopped.
+//
+// Вызов для неработающего сканера ничего не делает.
+func (s *Scanner) Stop() {
+	s.mu.RLock()
+	cancel := s.cancel
+	s.mu.RUnlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Приостановить сканирование.
+// Текущие запросы завершаются, новые не выполняются до вызова
+// метода Scanner.Resume() или остановки сканера.
+func (s *Scanner) Pause() error {
+	s.mu.Lock()
+	if s.state != ScannerScanning {
+		v := s.state.String()
+		s.mu.Unlock()
+		return fmt.Errorf("Нельзя приостановить сканер, когда он в режиме: \"%v\"", v)
+	}
+	s.state = ScannerPaused
+	s.resume = make(chan struct{})
+	s.mu.Unlock()
+	s.emitScanner()
+
+	s.log.Info("Сканирование приостановлено")
+	return nil
+}
+
+// Продолжить приостановленное сканирование.
+func (s *Scanner) Resume() error {
+	s.mu.Lock()
+	if s.state != ScannerPaused {
+		v := s.state.String()
+		s.mu.Unlock()
+		return fmt.Errorf("Нельзя продолжить сканирование, когда сканер в режиме: \"%v\"", v)
+	}
+	s.state = ScannerScanning
+	close(s.resume)
+	s.resume = nil
+	s.mu.Unlock()
+	s.emitScanner()
+
+	s.log.Info("Сканирование продолжено")
+	return nil
+}
+
+// Дождаться разрешения на выполнение запроса.
+// Блокирует поток, пока сканер на паузе. Возвращает ошибку,
+// если сканер остановлен.
+func (s *Scanner) wait() error {
+	for {
+		s.mu.RLock()
+		c := s.resume
+		s.mu.RUnlock()
+
+		if c == nil {
+			return s.ctx.Err()
+		}
+
+		select {
+		case <-c:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+// Занять место в очереди на запрос.
+// Возвращает ошибку, если сканер остановлен.
+func (s *Scanner) acquire() error {
+	if err := s.wait(); err != nil {
+		return err
+	}
+
+	select {
+	case s.limiter <- 0:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// Отменить обработку ресурса из-за остановки сканера.
+// Ресурс не должен быть заблокирован вызывающим кодом.
+func (s *Scanner) cancelSource(obj *Source, err error) {
+	obj.mu.Lock()
+	obj.state = SourceCanceled
+	obj.err = err
+	obj.mu.Unlock()
+	s.emitState(obj)
+	s.logSource(slog.LevelDebug, obj, "Обработка ресурса отменена", "err", err)
+}
+
+// Записать файл целиком или не записывать вовсе.
+//
+// Данные пишутся во временный файл в той же папке, который затем
+// переименовывается в итоговый. При ошибке временный файл удаляется,
+// поэтому прерванная запись не оставляет на диске обрезанных файлов.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
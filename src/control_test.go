@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Отмена контекста первого запуска не влияет на следующий запуск
+// с новым контекстом: так main перезапускает сканер после Ctrl+C.
+func TestStartAfterCanceledContext(t *testing.T) {
+	f := NewMemoryFetcher().Add("http://site.test/", "text/html", `<!DOCTYPE html><p>home</p>`)
+	params := ScannerParams{URL: "http://site.test/", Fetchers: map[string]Fetcher{"http": f}}
+
+	wait := func(s *Scanner) ScannerState {
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if st := s.State(); st != ScannerPreparing && st != ScannerScanning {
+				return st
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("сканирование не завершилось")
+		return 0
+	}
+
+	s := NewScanner()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	params.OutputDir = t.TempDir()
+	if err := s.Start(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	if st := wait(s); st != ScannerStopped {
+		t.Errorf("отменённый контекст: State() = %v, want %v", st, ScannerStopped)
+	}
+
+	params.OutputDir = t.TempDir()
+	if err := s.Start(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if st := wait(s); st != ScannerComplete {
+		t.Errorf("новый контекст: State() = %v, err: %v", st, s.Err())
+	}
+}
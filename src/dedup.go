@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Режим дедупликации файлов сайта по хешу содержимого.
+type DedupMode int
+
+// Получить текстовое представление режима дедупликации.
+func (v DedupMode) String() string {
+	switch v {
+	case DedupOff:
+		return "Выключена"
+	case DedupHardlink:
+		return "Жёсткие ссылки"
+	case DedupSymlink:
+		return "Символические ссылки"
+	case DedupManifest:
+		return "Ссылки в манифесте"
+	default:
+		return "Unknown"
+	}
+}
+
+// Получить код режима дедупликации: off, hardlink, symlink, manifest
+func (v DedupMode) MarshalText() ([]byte, error) {
+	switch v {
+	case DedupOff:
+		return []byte("off"), nil
+	case DedupHardlink:
+		return []byte("hardlink"), nil
+	case DedupSymlink:
+		return []byte("symlink"), nil
+	case DedupManifest:
+		return []byte("manifest"), nil
+	default:
+		return nil, fmt.Errorf("Неизвестный режим дедупликации: %d", int(v))
+	}
+}
+
+// Разобрать код режима дедупликации: off, hardlink, symlink, manifest
+func (v *DedupMode) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "off", "":
+		*v = DedupOff
+	case "hardlink":
+		*v = DedupHardlink
+	case "symlink":
+		*v = DedupSymlink
+	case "manifest":
+		*v = DedupManifest
+	default:
+		return fmt.Errorf("Неизвестный режим дедупликации: \"%s\"", text)
+	}
+	return nil
+}
+
+const (
+
+	// Каждый ресурс пишется отдельным файлом
+	DedupOff DedupMode = iota
+
+	// Дубликаты - жёсткие ссылки на первый файл с таким содержимым
+	DedupHardlink
+
+	// Дубликаты - символические ссылки на первый файл с таким содержимым
+	DedupSymlink
+
+	// Дубликаты не пишутся, ресурс ссылается на первый файл с таким
+	// содержимым. См.: Source.File(), Source.DuplicateOf()
+	DedupManifest
+)
+
+// Хранилище, поддерживающее ссылки на ранее записанные файлы.
+// Если хранилище его не поддерживает (ZIP архив), дубликаты в режимах
+// DedupHardlink и DedupSymlink пишутся целиком.
+type linker interface {
+
+	// Создать файл name, ссылающийся на ранее записанный файл target.
+	Link(name string, target string, symbolic bool) error
+}
+
+// Хранилище уникального содержимого
+type blobs struct {
+	mu sync.Mutex
+	m  map[string]*blob // Содержимое по хешу: SHA-256
+}
+
+// Уникальное содержимое
+type blob struct {
+	mu   sync.Mutex // Заблокирован до завершения записи файла
+	src  *Source    // Первый ресурс с таким содержимым
+	name string     // Файл с содержимым в хранилище
+	ok   bool       // Файл успешно записан
+}
+
+// Создать хранилище уникального содержимого.
+func newBlobs() *blobs {
+	return &blobs{m: make(map[string]*blob)}
+}
+
+// Сохранить тело ресурса с учётом дедупликации.
+// Возвращает имя файла в хранилище, в котором лежит содержимое
+// ресурса. Для режима DedupManifest это может быть файл другого
+// ресурса с таким же содержимым.
+func (s *Scanner) store(obj *Source, name string, body []byte) (string, error) {
+	if s.params.Dedup == DedupOff || len(body) == 0 {
+		return name, s.out.WriteFile(name, body)
+	}
+
+	hash := obj.Hash()
+	s.blobs.mu.Lock()
+	b, ok := s.blobs.m[hash]
+	if !ok {
+		// Первое появление содержимого:
+		b = &blob{src: obj, name: name}
+		b.mu.Lock()
+		s.blobs.m[hash] = b
+		s.blobs.mu.Unlock()
+
+		err := s.out.WriteFile(name, body)
+		b.ok = err == nil
+		b.mu.Unlock()
+		return name, err
+	}
+	s.blobs.mu.Unlock()
+
+	// Ждём запись первого файла:
+	b.mu.Lock()
+	ok = b.ok
+	b.mu.Unlock()
+	if !ok {
+		return name, s.out.WriteFile(name, body)
+	}
+
+	// Дубликат:
+	if s.params.Dedup == DedupManifest || name == b.name {
+		name = b.name
+	} else if l, ok := s.out.(linker); !ok {
+		// Хранилище не поддерживает ссылки:
+		return name, s.out.WriteFile(name, body)
+	} else if err := l.Link(name, b.name, s.params.Dedup == DedupSymlink); err != nil {
+		s.logSource(slog.LevelWarn, obj, "Не удалось создать ссылку на дубликат, файл будет записан целиком", "file", b.name, "err", err)
+		return name, s.out.WriteFile(name, body)
+	}
+
+	obj.mu.Lock()
+	obj.dupOf = b.src
+	obj.mu.Unlock()
+	s.logSource(slog.LevelDebug, obj, "Дубликат содержимого", "original", b.src.url.String())
+
+	return name, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+// Сайт с одинаковым содержимым двух файлов.
+func dupSite() *MemoryFetcher {
+	return NewMemoryFetcher().
+		Add("http://mem.test/", "text/html", `<!DOCTYPE html><link rel="stylesheet" href="/a.css"><link rel="stylesheet" href="/b.css">`).
+		Add("http://mem.test/a.css", "text/css", `body{color:red}`).
+		Add("http://mem.test/b.css", "text/css", `body{color:red}`)
+}
+
+func TestDedupHardlink(t *testing.T) {
+	s := runScanner(t, ScannerParams{
+		URL:      "http://mem.test/",
+		Dedup:    DedupHardlink,
+		Fetchers: map[string]Fetcher{"http": dupSite()},
+	})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+
+	a := findSource(t, s, "http://mem.test/a.css")
+	b := findSource(t, s, "http://mem.test/b.css")
+	if !(a.DuplicateOf() == nil && b.DuplicateOf() == a) && !(b.DuplicateOf() == nil && a.DuplicateOf() == b) {
+		t.Errorf("DuplicateOf() = %v, %v", a.DuplicateOf(), b.DuplicateOf())
+	}
+	fa, err := os.Stat(a.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fb, err := os.Stat(b.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(fa, fb) {
+		t.Error("дубликат не является жёсткой ссылкой")
+	}
+}
+
+func TestDedupLinksInZip(t *testing.T) {
+	for _, mode := range []DedupMode{DedupHardlink, DedupSymlink} {
+		s := runScanner(t, ScannerParams{
+			URL:          "http://mem.test/",
+			OutputFormat: OutputZIP,
+			Dedup:        mode,
+			Fetchers:     map[string]Fetcher{"http": dupSite()},
+		})
+		if s.State() != ScannerComplete {
+			t.Fatalf("%v: State() = %v, err: %v", mode, s.State(), s.Err())
+		}
+
+		// Архив не поддерживает ссылки, дубликат пишется целиком:
+		r, err := zip.OpenReader(s.Dir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := make(map[string]string)
+		for _, f := range r.File {
+			if name := path.Base(f.Name); name == "a.css" || name == "b.css" {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(rc)
+				rc.Close()
+				found[name] = string(data)
+			}
+		}
+		r.Close()
+		if found["a.css"] != `body{color:red}` || found["b.css"] != `body{color:red}` {
+			t.Errorf("%v: файлы в архиве: %q", mode, found)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Тип события сканера.
+type EventType int
+
+// Получить текстовое представление типа события.
+func (v EventType) String() string {
+	switch v {
+	case EventSourceAdd:
+		return "source_add"
+	case EventSourceState:
+		return "source_state"
+	case EventSourceDownload:
+		return "source_download"
+	case EventSourceSave:
+		return "source_save"
+	case EventSourceError:
+		return "source_error"
+	case EventScannerState:
+		return "scanner_state"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+
+	// Найден новый ресурс
+	EventSourceAdd EventType = iota
+
+	// Изменился статус ресурса
+	EventSourceState
+
+	// Тело ресурса скачано и распаковано
+	EventSourceDownload
+
+	// Ресурс сохранён в файловую систему
+	EventSourceSave
+
+	// Ошибка обработки ресурса.
+	// Ресурс перешёл в одно из конечных состояний ошибки:
+	// SourceRequestError, SourceDownloadError, SourceSaveError
+	EventSourceError
+
+	// Изменилось состояние сканера
+	EventScannerState
+)
+
+// Событие сканера.
+type Event struct {
+	Type   EventType    // Тип события
+	Time   time.Time    // Время события
+	Source *SourceInfo  // Снимок ресурса. Равно nil для событий сканера
+	State  ScannerState // Состояние сканера на момент события
+	Err    error        // Ошибка ресурса или сканера, если есть
+}
+
+// Наблюдатель за событиями сканера.
+//
+// Метод OnEvent() вызывается синхронно из рабочих горутин сканера,
+// поэтому он должен быстро возвращать управление и быть безопасным
+// для параллельного вызова.
+type Observer interface {
+	OnEvent(e Event)
+}
+
+// Функция как наблюдатель за событиями сканера.
+type ObserverFunc func(e Event)
+
+// Обработать событие сканера.
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+// Список наблюдателей сканера
+type observers struct {
+	mu sync.RWMutex
+	a  []Observer
+}
+
+// Подписать наблюдателя на события сканера.
+// Подписка сохраняется между запусками сканера.
+func (s *Scanner) Subscribe(o Observer) {
+	s.obs.mu.Lock()
+	defer s.obs.mu.Unlock()
+	s.obs.a = append(s.obs.a, o)
+}
+
+// Отписать наблюдателя от событий сканера.
+func (s *Scanner) Unsubscribe(o Observer) {
+	s.obs.mu.Lock()
+	defer s.obs.mu.Unlock()
+	for i, v := range s.obs.a {
+		if v == o {
+			s.obs.a = append(s.obs.a[:i:i], s.obs.a[i+1:]...)
+			return
+		}
+	}
+}
+
+// Получить канал событий сканера.
+//
+// Канал имеет буфер размером size. Если получатель не успевает
+// читать события, рабочие горутины сканера ждут освобождения буфера.
+// Вызовите функцию отмены, чтобы отписаться и закрыть канал.
+func (s *Scanner) Events(size int) (<-chan Event, func()) {
+	c := &chanObserver{c: make(chan Event, size), done: make(chan struct{})}
+	s.Subscribe(c)
+
+	var once sync.Once
+	return c.c, func() {
+		once.Do(func() {
+			s.Unsubscribe(c)
+			close(c.done)
+
+			// Ждём завершения текущих отправок:
+			c.mu.Lock()
+			c.closed = true
+			close(c.c)
+			c.mu.Unlock()
+		})
+	}
+}
+
+// Наблюдатель, пересылающий события в канал
+type chanObserver struct {
+	mu     sync.RWMutex
+	c      chan Event
+	done   chan struct{}
+	closed bool
+}
+
+// Переслать событие в канал.
+func (o *chanObserver) OnEvent(e Event) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if o.closed {
+		return
+	}
+
+	select {
+	case o.c <- e:
+	case <-o.done:
+	}
+}
+
+// Проверка наличия подписчиков.
+func (s *Scanner) observed() bool {
+	s.obs.mu.RLock()
+	defer s.obs.mu.RUnlock()
+	return len(s.obs.a) > 0
+}
+
+// Разослать событие всем наблюдателям.
+func (s *Scanner) emit(e Event) {
+	s.obs.mu.RLock()
+	a := make([]Observer, len(s.obs.a))
+	copy(a, s.obs.a)
+	s.obs.mu.RUnlock()
+
+	for _, o := range a {
+		o.OnEvent(e)
+	}
+}
+
+// Разослать событие ресурса.
+// Ресурс не должен быть заблокирован вызывающим кодом.
+func (s *Scanner) emitSource(t EventType, obj *Source) {
+	if !s.observed() {
+		return
+	}
+
+	info := obj.Info()
+	e := Event{
+		Type:   t,
+		Time:   time.Now(),
+		Source: &info,
+		State:  s.State(),
+		Err:    obj.Err(),
+	}
+	s.emit(e)
+}
+
+// Разослать событие о новом статусе ресурса.
+// Для некоторых статусов дополнительно рассылаются события:
+//   - SourceRead - тело ресурса скачано: EventSourceDownload;
+//   - SourceComplete - ресурс сохранён: EventSourceSave;
+//   - Ошибки обработки: EventSourceError.
+//
+// Ресурс не должен быть заблокирован вызывающим кодом.
+func (s *Scanner) emitState(obj *Source) {
+	if !s.observed() {
+		return
+	}
+
+	s.emitSource(EventSourceState, obj)
+	switch obj.State() {
+	case SourceRead:
+		s.emitSource(EventSourceDownload, obj)
+	case SourceComplete:
+		s.emitSource(EventSourceSave, obj)
+	case SourceRequestError, SourceDownloadError, SourceSaveError:
+		s.emitSource(EventSourceError, obj)
+	}
+}
+
+// Разослать событие о новом состоянии сканера.
+// Сканер не должен быть заблокирован вызывающим кодом.
+func (s *Scanner) emitScanner() {
+	if !s.observed() {
+		return
+	}
+
+	s.mu.RLock()
+	e := Event{
+		Type:  EventScannerState,
+		Time:  time.Now(),
+		State: s.state,
+		Err:   s.err,
+	}
+	s.mu.RUnlock()
+
+	s.emit(e)
+}
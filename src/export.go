@@ -0,0 +1,418 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Формат отчёта о сканировании.
+type ReportFormat int
+
+const (
+
+	// Отчёт в формате JSON: сводка и список всех ресурсов
+	ReportJSON ReportFormat = iota
+
+	// Отчёт в формате CSV: одна строка на каждый ресурс
+	ReportCSV
+
+	// Самодостаточная HTML страница с сортируемой таблицей ресурсов
+	ReportHTML
+)
+
+// Получить текстовое представление формата отчёта.
+func (v ReportFormat) String() string {
+	switch v {
+	case ReportJSON:
+		return "JSON"
+	case ReportCSV:
+		return "CSV"
+	case ReportHTML:
+		return "HTML"
+	default:
+		return "Unknown"
+	}
+}
+
+// Получить расширение файла для формата отчёта.
+func (v ReportFormat) Ext() string {
+	switch v {
+	case ReportJSON:
+		return "json"
+	case ReportCSV:
+		return "csv"
+	case ReportHTML:
+		return "html"
+	default:
+		return "txt"
+	}
+}
+
+// Снимок данных ресурса для отчёта.
+// Копия, не связанная с исходным ресурсом и безопасная для чтения.
+type SourceInfo struct {
+	URL        string    `json:"url"`
+	State      string    `json:"state"`
+	Status     string    `json:"status"`
+	Mime       string    `json:"mime"`
+	Charset    string    `json:"charset,omitempty"`
+	HTTPStatus int       `json:"http_status,omitempty"`
+	Size       int64     `json:"size"`
+	SizeWire   int64     `json:"size_wire"`
+	External   bool      `json:"external"`
+	Err        string    `json:"error,omitempty"`
+	ErrRead    string    `json:"error_read,omitempty"`
+	Repeats    int       `json:"repeats"`
+	Referrer   string    `json:"referrer,omitempty"`
+	Refs       []string  `json:"refs,omitempty"`
+	Depth      int       `json:"depth"`
+	File       string    `json:"file,omitempty"`
+	Hash       string    `json:"hash,omitempty"`
+	Duplicate  string    `json:"duplicate_of,omitempty"`
+	DateAdd    time.Time `json:"date_add"`
+	DateStart  time.Time `json:"date_start"`
+	DateFinish time.Time `json:"date_finish"`
+	Duration   int64     `json:"duration_ms"`
+}
+
+// Получить снимок данных ресурса для отчёта.
+func (s *Source) Info() SourceInfo {
+	refs := s.Refs()
+	depth := s.Depth()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v := SourceInfo{
+		URL:        s.url.String(),
+		State:      s.state.Code(),
+		Status:     s.state.String(),
+		Mime:       s.mime,
+		Charset:    s.charset,
+		HTTPStatus: s.status,
+		Hash:       s.hash,
+		Size:       s.size,
+		SizeWire:   s.sizeWire,
+		External:   s.isExternal,
+		Repeats:    s.repeats,
+		File:       s.file,
+		Depth:      depth,
+		DateAdd:    s.dateAdd,
+		DateStart:  s.dateStart,
+		DateFinish: s.dateFinish,
+	}
+	if s.err != nil {
+		v.Err = s.err.Error()
+	}
+	if s.errRead != nil {
+		v.ErrRead = s.errRead.Error()
+	}
+	if s.referrer != nil {
+		v.Referrer = s.referrer.String()
+	}
+	if s.dupOf != nil {
+		v.Duplicate = s.dupOf.url.String()
+	}
+	for _, ref := range refs {
+		v.Refs = append(v.Refs, ref.String())
+	}
+	if !s.dateStart.IsZero() && !s.dateFinish.IsZero() {
+		v.Duration = s.dateFinish.Sub(s.dateStart).Milliseconds()
+	}
+
+	return v
+}
+
+// Сводка о сканировании.
+// Содержит итоговые значения, выводимые в Scanner.Report().
+type ReportSummary struct {
+	URL           string         `json:"url"`
+	Dir           string         `json:"dir"`
+	State         string         `json:"state"`
+	Threads       int            `json:"threads"`
+	Count         int64          `json:"count"`
+	CountExternal int64          `json:"count_external"`
+	CountInternal int64          `json:"count_internal"`
+	CountByState  map[string]int `json:"count_by_state"`
+	Size          int64          `json:"size"`
+	SizeWire      int64          `json:"size_wire"`
+	DedupCount    int64          `json:"dedup_count"`
+	DedupSize     int64          `json:"dedup_size"`
+	DateStart     time.Time      `json:"date_start"`
+	DateScan      time.Time      `json:"date_scan"`
+	DateFinish    time.Time      `json:"date_finish"`
+	Duration      int64          `json:"duration_ms"`
+}
+
+// Получить сводку о текущем состоянии сканера.
+func (s *Scanner) Summary() ReportSummary {
+	s.mu.RLock()
+	sum := ReportSummary{
+		Dir:          s.dir,
+		State:        s.state.String(),
+		Threads:      s.threads,
+		CountByState: make(map[string]int),
+		DateStart:    s.dateStart,
+		DateScan:     s.dateScan,
+		DateFinish:   s.dateFinish,
+	}
+	if s.url != nil {
+		sum.URL = s.url.String()
+	}
+	src := s.sources
+	s.mu.RUnlock()
+
+	if sum.DateFinish.IsZero() {
+		sum.Duration = time.Since(sum.DateStart).Milliseconds()
+	} else {
+		sum.Duration = sum.DateFinish.Sub(sum.DateStart).Milliseconds()
+	}
+
+	if src == nil {
+		return sum
+	}
+	for _, obj := range src.List() {
+		sum.Count++
+
+		obj.mu.RLock()
+		if obj.isExternal {
+			sum.CountExternal++
+		} else {
+			sum.Size += obj.size
+			sum.SizeWire += obj.sizeWire
+		}
+		if obj.dupOf != nil {
+			sum.DedupCount++
+			sum.DedupSize += obj.size
+		}
+		sum.CountByState[obj.state.Code()]++
+		obj.mu.RUnlock()
+	}
+	sum.CountInternal = sum.Count - sum.CountExternal
+
+	return sum
+}
+
+// Получить снимки всех ресурсов для отчёта.
+func (s *Scanner) Infos() []SourceInfo {
+	s.mu.RLock()
+	src := s.sources
+	s.mu.RUnlock()
+	if src == nil {
+		return nil
+	}
+
+	a := src.List()
+	res := make([]SourceInfo, len(a))
+	for i, obj := range a {
+		res[i] = obj.Info()
+	}
+
+	return res
+}
+
+// Записать отчёт о сканировании в указанном формате.
+func (s *Scanner) Export(w io.Writer, format ReportFormat) error {
+	switch format {
+	case ReportJSON:
+		return s.ExportJSON(w)
+	case ReportCSV:
+		return s.ExportCSV(w)
+	case ReportHTML:
+		return s.ExportHTML(w)
+	default:
+		return fmt.Errorf("Неизвестный формат отчёта: %v", format)
+	}
+}
+
+// Сохранить отчёт о сканировании в файл.
+func (s *Scanner) ExportFile(path string, format ReportFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Не удалось создать файл отчёта: \"%v\": %w", path, err)
+	}
+
+	err = s.Export(f, format)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return fmt.Errorf("Не удалось записать отчёт %v в файл: \"%v\": %w", format, path, err)
+	}
+
+	return nil
+}
+
+// Записать отчёт в формате JSON.
+func (s *Scanner) ExportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(struct {
+		Summary ReportSummary `json:"summary"`
+		Sources []SourceInfo  `json:"sources"`
+	}{
+		Summary: s.Summary(),
+		Sources: s.Infos(),
+	})
+}
+
+// Записать отчёт в формате CSV.
+// Первая строка содержит заголовки колонок.
+func (s *Scanner) ExportCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{
+		"url", "state", "status", "mime", "charset", "http_status", "size", "size_wire", "external",
+		"error", "error_read", "repeats", "referrer", "depth", "file", "hash", "duplicate_of",
+		"date_add", "date_start", "date_finish", "duration_ms",
+	})
+
+	for _, v := range s.Infos() {
+		c.Write([]string{
+			v.URL,
+			v.State,
+			v.Status,
+			v.Mime,
+			v.Charset,
+			strconv.Itoa(v.HTTPStatus),
+			strconv.FormatInt(v.Size, 10),
+			strconv.FormatInt(v.SizeWire, 10),
+			strconv.FormatBool(v.External),
+			v.Err,
+			v.ErrRead,
+			strconv.Itoa(v.Repeats),
+			v.Referrer,
+			strconv.Itoa(v.Depth),
+			v.File,
+			v.Hash,
+			v.Duplicate,
+			csvDate(v.DateAdd),
+			csvDate(v.DateStart),
+			csvDate(v.DateFinish),
+			strconv.FormatInt(v.Duration, 10),
+		})
+	}
+
+	c.Flush()
+	return c.Error()
+}
+
+// Дата для CSV отчёта. Пустая строка для нулевой даты.
+func csvDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// Записать отчёт в виде HTML страницы.
+// Страница не требует внешних файлов, таблица сортируется
+// нажатием на заголовок колонки.
+func (s *Scanner) ExportHTML(w io.Writer) error {
+	sum := s.Summary()
+	return reportHTML.Execute(w, struct {
+		App       string
+		Summary   ReportSummary
+		Size      string
+		SizeWire  string
+		DedupSize string
+		Sources   []SourceInfo
+	}{
+		App:       APP_NAME + " v:" + VERSION,
+		Summary:   sum,
+		Size:      s.repSize(float64(sum.Size)),
+		SizeWire:  s.repSize(float64(sum.SizeWire)),
+		DedupSize: s.repSize(float64(sum.DedupSize)),
+		Sources:   s.Infos(),
+	})
+}
+
+// Шаблон HTML отчёта
+var reportHTML = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Отчёт сканирования: {{.Summary.URL}}</title>
+<style>
+body { font: 13px sans-serif; margin: 20px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 3px 6px; text-align: left; vertical-align: top; }
+th { background: #eee; cursor: pointer; user-select: none; position: sticky; top: 0; }
+th.asc:after { content: " \25B2"; }
+th.desc:after { content: " \25BC"; }
+td.num { text-align: right; white-space: nowrap; }
+tr.request_error, tr.download_error, tr.save_error { background: #fdd; }
+tr.skip { color: #888; }
+.summary td:first-child { font-weight: bold; }
+.summary { width: auto; margin-bottom: 20px; }
+</style>
+</head>
+<body>
+<h1>Отчёт сканирования</h1>
+<table class="summary">
+<tr><td>URL</td><td>{{.Summary.URL}}</td></tr>
+<tr><td>Папка</td><td>{{.Summary.Dir}}</td></tr>
+<tr><td>Статус</td><td>{{.Summary.State}}</td></tr>
+<tr><td>Кол-во всех ссылок</td><td>{{.Summary.Count}}</td></tr>
+<tr><td>Кол-во внешних ссылок</td><td>{{.Summary.CountExternal}}</td></tr>
+<tr><td>Кол-во внутренних ссылок</td><td>{{.Summary.CountInternal}}</td></tr>
+{{range $k, $v := .Summary.CountByState}}<tr><td>Статус: {{$k}}</td><td>{{$v}}</td></tr>
+{{end}}<tr><td>Объём данных</td><td>{{.Size}}</td></tr>
+<tr><td>Передано по сети</td><td>{{.SizeWire}}</td></tr>
+<tr><td>Сэкономлено дедупликацией</td><td>{{.DedupSize}}, файлов: {{.Summary.DedupCount}}</td></tr>
+<tr><td>Запуск</td><td>{{date .Summary.DateStart}}</td></tr>
+<tr><td>Завершение</td><td>{{date .Summary.DateFinish}}</td></tr>
+<tr><td>Время работы, мс</td><td>{{.Summary.Duration}}</td></tr>
+</table>
+<table id="sources">
+<thead><tr>
+<th>URL</th><th>Статус</th><th>Тип</th><th data-num>Размер</th><th data-num>По сети</th><th>Ошибка</th><th>Ошибка анализа</th><th data-num>Повторы</th><th>Источник</th><th data-num>Время, мс</th><th>Файл</th>
+</tr></thead>
+<tbody>
+{{range .Sources}}<tr class="{{.State}}"><td>{{.URL}}</td><td>{{.Status}}</td><td>{{.Mime}}</td><td class="num">{{.Size}}</td><td class="num">{{.SizeWire}}</td><td>{{.Err}}</td><td>{{.ErrRead}}</td><td class="num">{{.Repeats}}</td><td>{{.Referrer}}</td><td class="num">{{.Duration}}</td><td>{{.File}}</td></tr>
+{{end}}</tbody>
+</table>
+<p>{{.App}}</p>
+<script>
+(function () {
+	var table = document.getElementById("sources");
+	var heads = table.tHead.rows[0].cells;
+	for (var i = 0; i < heads.length; i++) {
+		heads[i].addEventListener("click", sort.bind(null, i));
+	}
+	function sort(col) {
+		var th = heads[col];
+		var dir = th.classList.contains("asc") ? -1 : 1;
+		var num = th.hasAttribute("data-num");
+		for (var i = 0; i < heads.length; i++) {
+			heads[i].classList.remove("asc", "desc");
+		}
+		th.classList.add(dir > 0 ? "asc" : "desc");
+		var body = table.tBodies[0];
+		var rows = Array.prototype.slice.call(body.rows);
+		rows.sort(function (a, b) {
+			var x = a.cells[col].textContent, y = b.cells[col].textContent;
+			if (num) {
+				return (Number(x) - Number(y)) * dir;
+			}
+			return x.localeCompare(y) * dir;
+		});
+		rows.forEach(function (r) { body.appendChild(r); });
+	}
+})();
+</script>
+</body>
+</html>
+`))
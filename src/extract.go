@@ -0,0 +1,205 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Способ разбора значения с ссылками.
+type ExtractKind int
+
+const (
+
+	// Значение - одна ссылка: src, href, ...
+	ExtractURL ExtractKind = iota
+
+	// Значение - список ссылок с дескрипторами: srcset, imagesrcset
+	ExtractSrcset
+
+	// Значение - задержка и ссылка: <meta http-equiv="refresh" content="5; url=...">
+	ExtractRefresh
+
+	// Значение - CSS код, ссылки в url() и @import: style="", <style>
+	ExtractCSS
+
+	// Значение - HTML документ: <iframe srcdoc>
+	ExtractHTML
+
+	// Значение - JavaScript код: <script>
+	ExtractJS
+
+	// Значение - структурированные данные JSON-LD: <script type="application/ld+json">
+	ExtractJSONLD
+)
+
+// Правило извлечения ссылок из HTML.
+//
+// Правило срабатывает для тега Tag ("*" - любой тег) с атрибутом Attr.
+// Если Attr пустой, разбирается текстовое содержимое тега, например:
+// <style>. Дополнительное условие Match может ограничить правило
+// значениями других атрибутов тега, например: <meta property="og:image">.
+type ExtractRule struct {
+	Tag   string                  // Имя тега в нижнем регистре или "*"
+	Attr  string                  // Имя атрибута или пустая строка для содержимого тега
+	Kind  ExtractKind             // Способ разбора значения
+	Match func(n *html.Node) bool // Дополнительное условие. Может быть nil
+}
+
+// Получить правила извлечения ссылок по умолчанию.
+// Возвращает новый список, который можно дополнить
+// своими правилами. См.: ScannerParams.ExtractRules
+func DefaultExtractRules() []ExtractRule {
+	return []ExtractRule{
+
+		// Любые теги:
+		{Tag: "*", Attr: "src", Kind: ExtractURL},
+		{Tag: "*", Attr: "href", Kind: ExtractURL}, // В том числе <link rel="icon|modulepreload|prefetch">, <svg><use xlink:href>
+		{Tag: "*", Attr: "srcset", Kind: ExtractSrcset},
+		{Tag: "*", Attr: "style", Kind: ExtractCSS},
+
+		// Отложенная загрузка:
+		{Tag: "*", Attr: "data-src", Kind: ExtractURL},
+		{Tag: "*", Attr: "data-srcset", Kind: ExtractSrcset},
+		{Tag: "*", Attr: "data-lazy-src", Kind: ExtractURL},
+		{Tag: "*", Attr: "data-original", Kind: ExtractURL},
+		{Tag: "*", Attr: "data-bg", Kind: ExtractURL},
+		{Tag: "*", Attr: "data-background", Kind: ExtractURL},
+
+		// Мультимедиа и встраиваемые объекты:
+		{Tag: "video", Attr: "poster", Kind: ExtractURL},
+		{Tag: "object", Attr: "data", Kind: ExtractURL},
+		{Tag: "link", Attr: "imagesrcset", Kind: ExtractSrcset},
+		{Tag: "iframe", Attr: "srcdoc", Kind: ExtractHTML},
+		{Tag: "style", Kind: ExtractCSS},
+		{Tag: "script", Kind: ExtractJSONLD, Match: attrIs("type", "application/ld+json")},
+		{Tag: "script", Kind: ExtractJS, Match: attrIs("type", "", "module", "text/javascript", "application/javascript", "text/ecmascript", "application/ecmascript")},
+
+		// Мета теги:
+		{Tag: "meta", Attr: "content", Kind: ExtractRefresh, Match: attrIs("http-equiv", "refresh")},
+		{Tag: "meta", Attr: "content", Kind: ExtractURL, Match: attrIs("property",
+			"og:image", "og:image:url", "og:image:secure_url", "og:video", "og:video:url", "og:audio")},
+		{Tag: "meta", Attr: "content", Kind: ExtractURL, Match: attrIs("name",
+			"twitter:image", "msapplication-tileimage", "msapplication-config")},
+
+		// Формы, отправляемые методом GET:
+		{Tag: "form", Attr: "action", Kind: ExtractURL, Match: attrIs("method", "", "get")},
+	}
+}
+
+// Условие правила: значение атрибута key тега равно одному из vals
+// без учёта регистра. Пустое значение в vals совпадает и с отсутствующим
+// атрибутом.
+func attrIs(key string, vals ...string) func(n *html.Node) bool {
+	return func(n *html.Node) bool {
+		v, ok := "", false
+		for _, a := range n.Attr {
+			if a.Key == key {
+				v, ok = a.Val, true
+				break
+			}
+		}
+		for _, want := range vals {
+			if strings.EqualFold(strings.TrimSpace(v), want) && (ok || want == "") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Получить правила извлечения ссылок для сканирования.
+func (s *Scanner) extractRules() []ExtractRule {
+	if s.params.ExtractRules != nil {
+		return s.params.ExtractRules
+	}
+	return defaultExtractRules
+}
+
+// Правила по умолчанию для внутреннего использования
+var defaultExtractRules = DefaultExtractRules()
+
+// Применить правило к значению атрибута или содержимому тега.
+func (s *Scanner) extract(obj *Source, e Edge, kind ExtractKind, n *html.Node, a *html.Attribute) {
+	var links []*url.URL
+	switch kind {
+	case ExtractURL:
+		links = s.parseSrc(obj, n, a)
+	case ExtractSrcset:
+		links = s.parseSrcset(obj, n, a)
+	case ExtractRefresh:
+		if v := refreshURL(a.Val); v != "" {
+			links = s.parseSrc(obj, n, &html.Attribute{Key: a.Key, Val: v})
+		}
+	case ExtractCSS:
+		s.readCSS(e, []byte(a.Val))
+	case ExtractHTML:
+		s.readHTML(obj, []byte(a.Val))
+	case ExtractJS:
+		s.readJS(e, []byte(a.Val))
+	case ExtractJSONLD:
+		s.readJSONLD(e, []byte(a.Val))
+	}
+
+	for _, u := range links {
+		s.follow(e, u)
+	}
+}
+
+// Получить ссылку из значения <meta http-equiv="refresh">:
+// "5; url=/page.html" -> "/page.html"
+func refreshURL(content string) string {
+	i := strings.IndexAny(content, ";,")
+	if i == -1 {
+		return ""
+	}
+	v := strings.TrimSpace(content[i+1:])
+	if len(v) >= 3 && strings.EqualFold(v[:3], "url") {
+		v = strings.TrimSpace(v[3:])
+		if !strings.HasPrefix(v, "=") {
+			return ""
+		}
+		v = strings.TrimSpace(v[1:])
+	}
+	return strings.Trim(v, "'\" ")
+}
+
+// Шаблоны для CSS
+var (
+	cssURL    = regexp.MustCompile(`(?i)url *\(`)             // url(...), URL (...), url('...'), ...
+	cssImport = regexp.MustCompile(`(?i)@import *["'` + "`]") // @import "...", @import '...'
+)
+
+// Найти ссылки в CSS коде: url(...), @import "..." и карту исходного кода
+// Относительные ссылки разрешаются от адреса документа со стилями.
+func (s *Scanner) readCSS(e Edge, body []byte) {
+	e.Attr = "url()"
+	res := cssURL.FindAllIndex(body, -1)
+	for i := 0; i < len(res); i++ {
+		s.followRef(e, readLink(body, res[i][1]))
+	}
+
+	e.Attr = "@import"
+	res = cssImport.FindAllIndex(body, -1)
+	for i := 0; i < len(res); i++ {
+		s.followRef(e, readLink(body, res[i][1]-1))
+	}
+
+	e.Attr = "sourceMappingURL"
+	for _, m := range cssSourceMap.FindAllSubmatch(body, -1) {
+		s.followRef(e, string(m[1]))
+	}
+}
+
+// Получить текстовое содержимое тега: <style>...</style>
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
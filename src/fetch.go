@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Загрузчик ресурсов по протоколу URL.
+//
+// Получает запрос и возвращает ответ в терминах HTTP: код ответа,
+// заголовки и поток тела, который закрывает сканер. Так разбор, повторы
+// запросов и сохранение ресурсов не зависят от протокола. Загрузчик сам
+// выполняет перенаправления и возвращает последний ответ, поле Request
+// ответа - запрос, на который он получен (nil - исходный запрос). Ошибка возвращается только при
+// сбое связи, после неё запрос повторяется. Отсутствие ресурса - это ответ
+// с кодом 404. Загрузчик должен прерывать запрос при отмене req.Context()
+// и допускать параллельные вызовы. См.: ScannerParams.Fetchers
+type Fetcher interface {
+	Fetch(req *http.Request) (*http.Response, error)
+}
+
+// Функция, реализующая интерфейс загрузчика.
+type FetcherFunc func(req *http.Request) (*http.Response, error)
+
+// Выполнить запрос.
+func (f FetcherFunc) Fetch(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Получить встроенные загрузчики по протоколам:
+// "http", "https", "file" и "ftp".
+func DefaultFetchers() map[string]Fetcher {
+	web := NewHTTPFetcher()
+	return map[string]Fetcher{
+		"http":  web,
+		"https": web,
+		"file":  NewFileFetcher(),
+		"ftp":   &FTPFetcher{},
+	}
+}
+
+// Загрузчик ресурсов через HTTP клиент.
+type ClientFetcher struct {
+	Client *http.Client
+}
+
+// Выполнить запрос клиентом.
+func (f *ClientFetcher) Fetch(req *http.Request) (*http.Response, error) {
+	return f.Client.Do(req)
+}
+
+// Создать загрузчик по HTTP(S).
+// Автоматическая распаковка gzip отключена, сжатие согласуется
+// и раскодируется сканером самостоятельно. См.: decodeBody()
+func NewHTTPFetcher() *ClientFetcher {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DisableCompression = true
+	return &ClientFetcher{Client: &http.Client{Transport: t}}
+}
+
+// Собрать загрузчики для работы сканера: встроенные, дополненные
+// и заменённые загрузчиками из параметров. См.: ScannerParams.Fetchers
+func (s *Scanner) newFetchers() map[string]Fetcher {
+	m := make(map[string]Fetcher, len(s.builtin)+len(s.params.Fetchers))
+	for k, v := range s.builtin {
+		m[k] = v
+	}
+	for k, v := range s.params.Fetchers {
+		k = strings.ToLower(k)
+		if v == nil {
+			delete(m, k)
+		} else {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// Выполнить запрос загрузчиком для протокола запроса.
+func (s *Scanner) fetch(req *http.Request) (*http.Response, error) {
+	f, ok := s.fetchers[req.URL.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("Нет загрузчика для протокола: \"%v\"", req.URL.Scheme)
+	}
+	resp, err := f.Fetch(req)
+	if err == nil && resp.Request == nil {
+		// Загрузчик не указал запрос, на который получен ответ:
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// Получить список протоколов, для которых есть загрузчики.
+func (s *Scanner) schemes() []string {
+	a := make([]string, 0, len(s.fetchers))
+	for k := range s.fetchers {
+		a = append(a, k)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// Создать ответ загрузчика на запрос.
+// Если тело не задано, ответ будет с пустым телом.
+func newResponse(req *http.Request, code int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          http.NoBody,
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if len(body) > 0 && req.Method != http.MethodHead {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return resp
+}
+
+// Загрузчик ресурсов из памяти.
+//
+// Сайт задаётся набором ответов по адресам, запросы сохраняются в журнал.
+// Нужен для проверки сканера и парсеров на сайтах в памяти, без сети и
+// диска: ScannerParams.Fetchers = map[string]Fetcher{"http": f}.
+// Адреса без ответа возвращают 404. Безопасен для параллельного использования.
+type MemoryFetcher struct {
+	mu       sync.RWMutex
+	files    map[string]*MemoryFile
+	requests []string
+}
+
+// Ответ загрузчика из памяти.
+type MemoryFile struct {
+	Status int         // Код ответа. 0 - 200 OK
+	Header http.Header // Заголовки ответа. Может быть nil
+	Body   []byte      // Тело ответа
+	Err    error       // Ошибка связи вместо ответа: для проверки повторов запросов
+}
+
+// Создать пустой загрузчик из памяти.
+func NewMemoryFetcher() *MemoryFetcher {
+	return &MemoryFetcher{files: make(map[string]*MemoryFile)}
+}
+
+// Добавить ресурс с кодом ответа 200.
+// Адрес задаётся полностью: "http://site.ru/index.html".
+func (f *MemoryFetcher) Add(u string, contentType string, body string) *MemoryFetcher {
+	h := make(http.Header)
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	return f.Set(u, &MemoryFile{Header: h, Body: []byte(body)})
+}
+
+// Добавить перенаправление на другой адрес.
+func (f *MemoryFetcher) Redirect(u string, to string) *MemoryFetcher {
+	h := make(http.Header)
+	h.Set("Location", to)
+	return f.Set(u, &MemoryFile{Status: http.StatusMovedPermanently, Header: h})
+}
+
+// Задать ответ для адреса.
+func (f *MemoryFetcher) Set(u string, file *MemoryFile) *MemoryFetcher {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[memoryKey(u)] = file
+	return f
+}
+
+// Получить журнал запросов: метод и адрес, в порядке поступления.
+// Возвращает копию, безопасную для внесения изменений.
+func (f *MemoryFetcher) Requests() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	a2 := make([]string, len(f.requests))
+	copy(a2, f.requests)
+	return a2
+}
+
+// Выполнить запрос: найти ответ по адресу и пройти по перенаправлениям.
+func (f *MemoryFetcher) Fetch(req *http.Request) (*http.Response, error) {
+	for i := 0; ; i++ {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+
+		f.mu.Lock()
+		f.requests = append(f.requests, req.Method+" "+req.URL.String())
+		file := f.files[memoryKey(req.URL.String())]
+		f.mu.Unlock()
+
+		if file == nil {
+			return newResponse(req, http.StatusNotFound, nil, nil), nil
+		}
+		if file.Err != nil {
+			return nil, file.Err
+		}
+		code := file.Status
+		if code == 0 {
+			code = http.StatusOK
+		}
+		resp := newResponse(req, code, file.Header.Clone(), file.Body)
+
+		// Перенаправление:
+		loc := resp.Header.Get("Location")
+		if code < 300 || code >= 400 || loc == "" {
+			return resp, nil
+		}
+		if i >= 10 {
+			return nil, fmt.Errorf("Слишком много перенаправлений: \"%v\"", req.URL)
+		}
+		to, err := req.URL.Parse(loc)
+		if err != nil {
+			return nil, err
+		}
+		next := req.Clone(req.Context())
+		next.URL = to
+		next.Host = to.Host
+		req = next
+	}
+}
+
+// Ключ адреса загрузчика из памяти: без фрагмента.
+func memoryKey(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
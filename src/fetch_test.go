@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Количество запросов с заданным методом и адресом в журнале.
+func countRequests(f *MemoryFetcher, req string) int {
+	n := 0
+	for _, v := range f.Requests() {
+		if v == req {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMemoryFetcher(t *testing.T) {
+	f := NewMemoryFetcher().
+		Add("http://mem.test/a", "text/plain", "a").
+		Redirect("http://mem.test/b", "/a")
+
+	req, _ := http.NewRequest(http.MethodGet, "http://mem.test/b#top", nil)
+	resp, err := f.Fetch(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "a" || resp.Request.URL.String() != "http://mem.test/a" {
+		t.Errorf("перенаправление: %v %q %v", resp.StatusCode, body, resp.Request.URL)
+	}
+
+	req, _ = http.NewRequest(http.MethodHead, "http://mem.test/none", nil)
+	if resp, err = f.Fetch(req); err != nil || resp.StatusCode != 404 {
+		t.Errorf("нет ресурса: %v %v", resp, err)
+	}
+
+	want := []string{"GET http://mem.test/b#top", "GET http://mem.test/a", "HEAD http://mem.test/none"}
+	if got := f.Requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Requests() = %q, want %q", got, want)
+	}
+}
+
+func TestScanMemorySite(t *testing.T) {
+	boom := errors.New("connection reset")
+	f := NewMemoryFetcher().
+		Add("http://mem.test/", "text/html", `<!DOCTYPE html><a href="/a.html">a</a><a href="/old">old</a><a href="/none">none</a><a href="/flaky">flaky</a>`).
+		Add("http://mem.test/a.html", "text/html", `<!DOCTYPE html><link rel="stylesheet" href="css/s.css"><img src="i.png">`).
+		Add("http://mem.test/css/s.css", "text/css", `body{background:url(../i.png)}`).
+		Add("http://mem.test/i.png", "image/png", "\x89PNG\r\n\x1a\n").
+		Add("http://mem.test/new.html", "text/html", `<!DOCTYPE html><p>new</p>`).
+		Redirect("http://mem.test/old", "/new.html")
+	f.Set("http://mem.test/flaky", &MemoryFile{Err: boom})
+
+	s := runScanner(t, ScannerParams{
+		URL:        "http://mem.test/",
+		RepeatsMax: 2,
+		Fetchers:   map[string]Fetcher{"http": f},
+	})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+
+	// Сохранённые ресурсы:
+	for u, want := range map[string]string{
+		"http://mem.test/a.html":    "img",
+		"http://mem.test/css/s.css": "background",
+		"http://mem.test/i.png":     "PNG",
+	} {
+		obj := findSource(t, s, u)
+		if obj.State() != SourceComplete || obj.Status() != 200 {
+			t.Errorf("%v: %v %v", u, obj.State(), obj.Status())
+			continue
+		}
+		if v := sourceFile(t, obj); !strings.Contains(v, want) {
+			t.Errorf("%v: содержимое %q", u, v)
+		}
+	}
+
+	// Перенаправление:
+	old := findSource(t, s, "http://mem.test/old")
+	if old.FinalURL() == nil || old.FinalURL().String() != "http://mem.test/new.html" {
+		t.Errorf("FinalURL() = %v", old.FinalURL())
+	}
+	if v := sourceFile(t, old); v != `<!DOCTYPE html><p>new</p>` {
+		t.Errorf("перенаправление: содержимое %q", v)
+	}
+
+	// Нет ресурса:
+	if obj := findSource(t, s, "http://mem.test/none"); obj.Status() != 404 || obj.File() != "" {
+		t.Errorf("404: %v %v %q", obj.State(), obj.Status(), obj.File())
+	}
+
+	// Повторы запросов после ошибки связи:
+	flaky := findSource(t, s, "http://mem.test/flaky")
+	if flaky.State() != SourceRequestError || !errors.Is(flaky.Err(), boom) {
+		t.Errorf("ошибка связи: %v %v", flaky.State(), flaky.Err())
+	}
+	if n := countRequests(f, "GET http://mem.test/flaky"); n != 3 {
+		t.Errorf("запросов с ошибкой: %v, want 3", n)
+	}
+
+	// Каждый ресурс запрошен один раз:
+	for _, u := range []string{"/", "/a.html", "/css/s.css", "/i.png", "/old", "/new.html", "/none"} {
+		if n := countRequests(f, "GET http://mem.test"+u); n != 1 {
+			t.Errorf("запросов %v: %v, want 1", u, n)
+		}
+	}
+}
+
+func TestScanFetcherOverrides(t *testing.T) {
+	s := runScanner(t, ScannerParams{URL: "gopher://site.test/"})
+	var e *URLError
+	if s.State() != ScannerIncorrectURL || !errors.As(s.Err(), &e) || e.Kind != URLScheme {
+		t.Errorf("неизвестный протокол: %v %v", s.State(), s.Err())
+	}
+
+	s = runScanner(t, ScannerParams{URL: "http://site.test/", Fetchers: map[string]Fetcher{"http": nil}})
+	if s.State() != ScannerIncorrectURL || !errors.As(s.Err(), &e) || e.Kind != URLScheme {
+		t.Errorf("отключённый протокол: %v %v", s.State(), s.Err())
+	}
+
+	// Свой протокол:
+	f := NewMemoryFetcher().Add("mem://site.test/", "text/html", `<!DOCTYPE html><a href="http://site.test/x">x</a>`)
+	s = runScanner(t, ScannerParams{URL: "mem://site.test/", Fetchers: map[string]Fetcher{"MEM": f}})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+	if obj := findSource(t, s, "http://site.test/x"); !obj.IsExternal() {
+		t.Errorf("ссылка на другой протокол должна быть внешней")
+	}
+
+	// Ответ без исходного запроса:
+	fn := FetcherFunc(func(req *http.Request) (*http.Response, error) {
+		h := make(http.Header)
+		h.Set("Content-Type", "text/html")
+		resp := newResponse(req, http.StatusOK, h, []byte(`<!DOCTYPE html><a href="/a.html">a</a><a href="http://other.test/">b</a>`))
+		resp.Request = nil
+		return resp, nil
+	})
+	s = runScanner(t, ScannerParams{
+		URL:           "http://site.test/",
+		WARC:          true,
+		CheckExternal: true,
+		Fetchers:      map[string]Fetcher{"http": fn},
+	})
+	if s.State() != ScannerComplete {
+		t.Fatalf("ответ без запроса: %v, err: %v", s.State(), s.Err())
+	}
+	if obj := findSource(t, s, "http://site.test/a.html"); obj.State() != SourceComplete || obj.FinalURL() != nil {
+		t.Errorf("ответ без запроса: %v %v", obj.State(), obj.FinalURL())
+	}
+	if obj := findSource(t, s, "http://other.test/"); obj.Status() != 200 {
+		t.Errorf("проверка внешней ссылки: %v %v %v", obj.State(), obj.Status(), obj.Err())
+	}
+}
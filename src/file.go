@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Файловая система для запросов по протоколу file://
+// Путь URL - абсолютный путь на диске: "/home/user/site/index.html",
+// в Windows - с буквой диска: "/C:/site/index.html".
+//
+// Запросы выполняются через http.NewFileTransport(), поэтому сайт на
+// диске обрабатывается так же, как веб-сервером: для папок отдаётся
+// index.html или список файлов, папки без "/" в конце перенаправляются,
+// тип содержимого определяется по расширению файла.
+type localFS struct{}
+
+// Открыть файл по пути URL.
+func (localFS) Open(name string) (http.File, error) {
+	return os.Open(localPath(name))
+}
+
+// Создать загрузчик файлов с диска по адресам file://
+// См.: localFS
+func NewFileFetcher() *ClientFetcher {
+	t := &http.Transport{}
+	t.RegisterProtocol("file", http.NewFileTransport(localFS{}))
+	return &ClientFetcher{Client: &http.Client{Transport: t}}
+}
+
+// Получить путь на диске из пути URL file://
+// "/C:/site/index.html" -> "C:\site\index.html" в Windows.
+func localPath(p string) string {
+	if runtime.GOOS == "windows" && len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+// Путь к корню сайта в URL.
+// Для сайтов по HTTP - "/", для сайтов на диске - папка исходного
+// адреса: "/home/user/site/". См.: parseURL()
+func (s *Scanner) rootPath() string {
+	if s.url.Scheme != "file" {
+		return "/"
+	}
+	return s.url.Path[:strings.LastIndexByte(s.url.Path, '/')+1]
+}
+
+// Путь ресурса от корня сайта: "/css/style.css".
+// Для сайтов на диске путь отсчитывается от папки сайта.
+func (s *Scanner) sitePath(u *url.URL) string {
+	return "/" + strings.TrimPrefix(u.Path, s.rootPath())
+}
+
+// Проверить, является ли ресурс внешним: другой хост или
+// протокол, а для сайта на диске - файл вне папки сайта.
+// Протоколы http и https считаются одним сайтом.
+func (s *Scanner) isExternalURL(u *url.URL) bool {
+	if s.url.Hostname() != u.Hostname() {
+		return true
+	}
+	if u.Scheme != s.url.Scheme && !(isWebScheme(u.Scheme) && isWebScheme(s.url.Scheme)) {
+		return true
+	}
+	if s.url.Scheme == "file" {
+		return !strings.HasPrefix(u.Path, s.rootPath())
+	}
+	return false
+}
+
+// Протокол веб-сайта: http или https.
+func isWebScheme(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
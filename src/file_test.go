@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanLocalFolder(t *testing.T) {
+	root := t.TempDir()
+	site := filepath.Join(root, "site")
+	files := map[string]string{
+		"site/index.html":    `<!DOCTYPE html><link rel="stylesheet" href="css/s.css"><a href="sub/page.html">p</a><a href="../secret.html">x</a><a href="missing.html">m</a>`,
+		"site/css/s.css":     `a{background:url(../img.png)}`,
+		"site/img.png":       "\x89PNG\r\n\x1a\n",
+		"site/sub/page.html": `<!DOCTYPE html><a href="../index.html">home</a>`,
+		"secret.html":        `secret`,
+	}
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0777)
+		if err := os.WriteFile(p, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := runScanner(t, ScannerParams{URL: site})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+	base := "file://" + filepath.ToSlash(site)
+	if !strings.HasPrefix(base, "file:///") {
+		base = "file:///" + strings.TrimPrefix(base, "file://")
+	}
+
+	for name, want := range map[string]string{
+		"/css/s.css":     "background",
+		"/img.png":       "PNG",
+		"/sub/page.html": "home",
+	} {
+		obj := findSource(t, s, base+name)
+		v := sourceFile(t, obj)
+		if !strings.Contains(v, want) {
+			t.Errorf("%v: содержимое %q", name, v)
+		}
+		rel, _ := filepath.Rel(s.Dir(), obj.File())
+		if filepath.ToSlash(rel) != strings.TrimPrefix(name, "/") {
+			t.Errorf("%v: файл %v", name, rel)
+		}
+	}
+
+	if obj := findSource(t, s, base+"/missing.html"); obj.Status() != 404 {
+		t.Errorf("нет файла: %v %v", obj.State(), obj.Status())
+	}
+	secret := "file://" + filepath.ToSlash(filepath.Join(root, "secret.html"))
+	if !strings.HasPrefix(secret, "file:///") {
+		secret = "file:///" + strings.TrimPrefix(secret, "file://")
+	}
+	if obj := findSource(t, s, secret); !obj.IsExternal() || obj.File() != "" {
+		t.Errorf("файл вне папки сайта: external %v, file %q", obj.IsExternal(), obj.File())
+	}
+}
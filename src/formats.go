@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Найти ссылки в теле ресурса.
+// Способ поиска выбирается по типу содержимого: заголовку
+// Content-Type, расширению файла и сигнатуре данных mim.
+func (s *Scanner) readBody(obj *Source, mim string, body []byte) {
+	obj.mu.RLock()
+	typ := strings.ToLower(obj.header.Get("Content-Type"))
+	obj.mu.RUnlock()
+	name := strings.ToLower(path.Base(obj.url.Path))
+	ext := path.Ext(name)
+	s.readSourceMapHeader(obj)
+
+	// All mime types:
+	// https://www.iana.org/assignments/media-types/media-types.xhtml
+	switch {
+	case strings.Contains(mim, "text/html"):
+		s.readHTML(obj, body)
+	case strings.Contains(mim, "application/pdf") || strings.Contains(typ, "application/pdf"):
+		s.readPDF(obj, body)
+	case strings.Contains(typ, "xml") || strings.Contains(mim, "xml") || ext == ".svg" || ext == ".rss" || ext == ".atom":
+		if !s.readXML(obj, body) {
+			s.readTXT(obj, body)
+		}
+	case strings.Contains(mim, "application/octet-stream") ||
+		strings.Contains(mim, "model") ||
+		strings.Contains(mim, "font") ||
+		strings.Contains(mim, "image") ||
+		strings.Contains(mim, "video") ||
+		strings.Contains(mim, "audio") ||
+		strings.Contains(mim, "application/ogg"):
+		// Игнорируем анализ этих типов..
+	case ext == ".map":
+		s.readSourceMap(Edge{From: obj}, body)
+	case isScript(obj):
+		s.readJS(Edge{From: obj}, body)
+	case strings.Contains(typ, "manifest+json") || ext == ".webmanifest" || name == "manifest.json":
+		s.readWebManifest(Edge{From: obj}, body)
+	case strings.Contains(typ, "json") || ext == ".json":
+		if !s.readWebManifest(Edge{From: obj}, body) {
+			s.readTXT(obj, body)
+		}
+	default:
+		s.readTXT(obj, body)
+	}
+}
+
+// Запустить сканирование ссылки, найденной в документе.
+// Относительная ссылка разрешается от адреса документа.
+// Пустые ссылки и ссылки на фрагмент документа пропускаются.
+func (s *Scanner) followRef(e Edge, v string) {
+	v = strings.TrimSpace(v)
+	if v == "" || strings.HasPrefix(v, "#") {
+		return
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		s.log.Debug("Ошибка разбора ссылки", "tag", e.Tag, "attr", e.Attr, "value", v, "err", err)
+		return
+	}
+	if u.Scheme == "data" || u.Scheme == "javascript" {
+		return
+	}
+
+	base := s.url
+	if e.From != nil && e.From.url != nil {
+		base = e.From.url
+	}
+	s.follow(e, base.ResolveReference(u))
+}
+
+// Найти ссылки в XML документе: SVG, лента RSS/Atom, browserconfig.xml
+// Вид документа определяется по корневому тегу. Возвращает false,
+// если вид документа не поддерживается или это не XML.
+func (s *Scanner) readXML(obj *Source, body []byte) bool {
+	d := newXMLDecoder(body)
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return false
+		}
+		if v, ok := t.(xml.StartElement); ok {
+			switch strings.ToLower(v.Name.Local) {
+			case "svg":
+				s.readSVG(obj, body)
+			case "rss", "feed", "rdf":
+				s.readFeed(obj, body)
+			case "browserconfig":
+				s.readBrowserconfig(obj, body)
+			default:
+				return false
+			}
+			return true
+		}
+	}
+}
+
+// Создать нестрогий XML декодер: документы в интернете
+// часто содержат HTML сущности и ошибки разметки.
+func newXMLDecoder(body []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+		if v, err := charset.NewReaderLabel(label, r); err == nil {
+			return v, nil
+		}
+		return r, nil
+	}
+	return d
+}
+
+// Найти ссылки в SVG: href, xlink:href, url() в атрибутах и <style>
+func (s *Scanner) readSVG(obj *Source, body []byte) {
+	d := newXMLDecoder(body)
+	var tags []string
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return
+		}
+
+		switch v := t.(type) {
+		case xml.StartElement:
+			tag := v.Name.Local
+			tags = append(tags, tag)
+			for _, a := range v.Attr {
+				e := Edge{From: obj, Tag: tag, Attr: a.Name.Local}
+				switch {
+				case a.Name.Local == "href":
+					s.followRef(e, a.Value)
+				case a.Name.Local == "style" || strings.Contains(a.Value, "url("):
+					s.readCSS(e, []byte(a.Value))
+				}
+			}
+		case xml.EndElement:
+			if len(tags) > 0 {
+				tags = tags[:len(tags)-1]
+			}
+		case xml.CharData:
+			if len(tags) > 0 && tags[len(tags)-1] == "style" {
+				s.readCSS(Edge{From: obj, Tag: "style"}, v)
+			}
+		}
+	}
+}
+
+// Теги лент RSS и Atom, текст которых - ссылка
+var feedText = map[string]bool{
+	"link":     true, // RSS: <item><link>
+	"url":      true, // RSS: <image><url>
+	"comments": true, // RSS: <item><comments>
+	"icon":     true, // Atom: <feed><icon>
+	"logo":     true, // Atom: <feed><logo>
+}
+
+// Найти ссылки в ленте RSS или Atom: ссылки на записи,
+// вложения (enclosure, media:content), изображения ленты.
+func (s *Scanner) readFeed(obj *Source, body []byte) {
+	d := newXMLDecoder(body)
+	var tag string
+	var text strings.Builder
+	var permalink bool
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return
+		}
+
+		switch v := t.(type) {
+		case xml.StartElement:
+			tag = strings.ToLower(v.Name.Local)
+			text.Reset()
+			permalink = true
+			for _, a := range v.Attr {
+				switch a.Name.Local {
+				case "href", "url", "src":
+					// Atom: <link href>, <content src>; RSS: <enclosure url>, <media:content url>
+					s.followRef(Edge{From: obj, Tag: v.Name.Local, Attr: a.Name.Local}, a.Value)
+				case "isPermaLink":
+					permalink = a.Value != "false"
+				}
+			}
+		case xml.CharData:
+			text.Write(v)
+		case xml.EndElement:
+			name := strings.ToLower(v.Name.Local)
+			if name == tag && (feedText[name] || (name == "guid" && permalink)) {
+				if link := strings.TrimSpace(text.String()); !strings.ContainsAny(link, " \t\n<>") {
+					s.followRef(Edge{From: obj, Tag: v.Name.Local}, link)
+				}
+			}
+			tag = ""
+		}
+	}
+}
+
+// Найти ссылки в browserconfig.xml: изображения плиток
+// и адреса уведомлений в атрибутах src.
+func (s *Scanner) readBrowserconfig(obj *Source, body []byte) {
+	d := newXMLDecoder(body)
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return
+		}
+		if v, ok := t.(xml.StartElement); ok {
+			for _, a := range v.Attr {
+				if a.Name.Local == "src" {
+					s.followRef(Edge{From: obj, Tag: v.Name.Local, Attr: a.Name.Local}, a.Value)
+				}
+			}
+		}
+	}
+}
+
+// Найти ссылки в манифесте веб приложения: start_url, иконки,
+// снимки экрана, ярлыки. Возвращает false, если это не JSON объект
+// или в нём нет полей манифеста.
+// См.: https://www.w3.org/TR/appmanifest/
+func (s *Scanner) readWebManifest(e Edge, body []byte) bool {
+	var m map[string]any
+	if json.Unmarshal(body, &m) != nil {
+		return false
+	}
+	if _, ok := m["start_url"]; !ok {
+		if _, ok := m["icons"]; !ok {
+			return false
+		}
+	}
+
+	var walk func(key string, v any)
+	walk = func(key string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, v2 := range v {
+				walk(k, v2)
+			}
+			if u, ok := v["url"].(string); ok && key == "shortcuts" {
+				s.followRef(Edge{From: e.From, Attr: "url"}, u)
+			}
+		case []any:
+			for _, v2 := range v {
+				walk(key, v2)
+			}
+		case string:
+			if key == "src" || key == "start_url" {
+				s.followRef(Edge{From: e.From, Attr: key}, v)
+			}
+		}
+	}
+	walk("", m)
+
+	return true
+}
+
+// Свойства JSON-LD, значения которых - ссылки на ресурсы
+var jsonLDKeys = map[string]bool{
+	"url":                true,
+	"image":              true,
+	"logo":               true,
+	"thumbnail":          true,
+	"thumbnailUrl":       true,
+	"contentUrl":         true,
+	"embedUrl":           true,
+	"photo":              true,
+	"primaryImageOfPage": true,
+}
+
+// Найти ссылки в структурированных данных JSON-LD:
+// <script type="application/ld+json">
+func (s *Scanner) readJSONLD(e Edge, body []byte) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		s.log.Debug("Ошибка разбора JSON-LD", "err", err)
+		return
+	}
+
+	var walk func(key string, v any)
+	walk = func(key string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, v2 := range v {
+				walk(k, v2)
+			}
+		case []any:
+			for _, v2 := range v {
+				walk(key, v2)
+			}
+		case string:
+			if jsonLDKeys[key] {
+				e.Attr = key
+				s.followRef(e, v)
+			}
+		}
+	}
+	walk("", v)
+}
+
+// Ссылка в аннотации PDF: /URI (http://...) или /URI <68747470...>
+var pdfURI = regexp.MustCompile(`/URI\s*[(<]`)
+
+// Начало потока данных PDF. Граница слова отсекает конец потока: endstream
+var pdfStream = regexp.MustCompile(`\bstream\r?\n`)
+
+// Найти ссылки в аннотациях PDF документа.
+// Аннотации могут лежать в сжатых потоках объектов (PDF 1.5+),
+// поэтому потоки, сжатые Deflate, распаковываются.
+func (s *Scanner) readPDF(obj *Source, body []byte) {
+	s.readPDFLinks(obj, body)
+
+	for _, m := range pdfStream.FindAllIndex(body, -1) {
+		end := bytes.Index(body[m[1]:], []byte("endstream"))
+		if end == -1 {
+			continue
+		}
+		z, err := zlib.NewReader(bytes.NewReader(body[m[1] : m[1]+end]))
+		if err != nil {
+			continue
+		}
+		data, _ := io.ReadAll(io.LimitReader(z, 16<<20))
+		z.Close()
+		if bytes.Contains(data, []byte("/URI")) {
+			s.readPDFLinks(obj, data)
+		}
+	}
+}
+
+// Найти ссылки /URI в объектах PDF.
+func (s *Scanner) readPDFLinks(obj *Source, data []byte) {
+	for _, m := range pdfURI.FindAllIndex(data, -1) {
+		var v string
+		if data[m[1]-1] == '(' {
+			v = pdfString(data[m[1]:])
+		} else {
+			end := bytes.IndexByte(data[m[1]:], '>')
+			if end == -1 {
+				continue
+			}
+			h := bytes.Map(func(r rune) rune {
+				if r <= ' ' {
+					return -1
+				}
+				return r
+			}, data[m[1]:m[1]+end])
+			if len(h)%2 == 1 {
+				h = append(h, '0')
+			}
+			b, err := hex.DecodeString(string(h))
+			if err != nil {
+				continue
+			}
+			v = string(b)
+		}
+		s.followRef(Edge{From: obj, Attr: "/URI"}, v)
+	}
+}
+
+// Прочитать литеральную строку PDF после открывающей скобки.
+// Вложенные скобки должны быть сбалансированы, escape
+// последовательности обрабатываются. См.: PDF 32000, 7.3.4.2
+func pdfString(b []byte) string {
+	var res strings.Builder
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return res.String()
+			}
+			depth--
+		case '\\':
+			i++
+			if i >= len(b) {
+				return res.String()
+			}
+			switch c = b[i]; c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				if c == '\r' && i+1 < len(b) && b[i+1] == '\n' {
+					i++
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := 0
+					for k := 0; k < 3 && i < len(b) && b[i] >= '0' && b[i] <= '7'; k++ {
+						n = n*8 + int(b[i]-'0')
+						i++
+					}
+					i--
+					c = byte(n)
+				}
+			}
+		}
+		res.WriteByte(c)
+	}
+	return res.String()
+}
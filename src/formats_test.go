@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReadHTMLSkipsScriptText(t *testing.T) {
+	page := `<html><head>
+<script>var n = a / b; // http://comment.test/
+/* //block.test/x */</script>
+<style>a{background:url(/bg.png)}</style>
+</head><body>
+<!-- http://hidden.test/ -->
+<p>Текст страницы</p>
+</body></html>`
+	got := collectLinks(t, "http://site.test/", func(s *Scanner, obj *Source) {
+		s.readBody(obj, "text/html; charset=utf-8", []byte(page))
+	})
+	for _, v := range got {
+		if strings.Contains(v, "comment.test") || strings.Contains(v, "block.test") || strings.Contains(v, "hidden.test") {
+			t.Errorf("ссылка из скрипта или комментария: %q", v)
+		}
+	}
+	if len(got) != 1 || got[0] != "url() http://site.test/bg.png" {
+		t.Errorf("links = %q, want only url() from <style>", got)
+	}
+}
+
+// Ссылки, найденные в теле документа по типу содержимого, по алфавиту.
+func bodyLinks(t *testing.T, base string, mim string, body string) []string {
+	t.Helper()
+	got := collectLinks(t, base, func(s *Scanner, obj *Source) {
+		s.readBody(obj, mim, []byte(body))
+	})
+	sort.Strings(got)
+	return got
+}
+
+func TestReadSVG(t *testing.T) {
+	svg := `<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">
+<style>.a{background:url(img/bg.png)}</style>
+<use xlink:href="sprite.svg#icon"/>
+<image href="/photo.jpg"/>
+<rect style="fill:url(#grad)" filter="url(filters.svg#blur)"/>
+</svg>`
+	got := bodyLinks(t, "http://site.test/img/logo.svg", "text/xml; charset=utf-8", svg)
+	want := []string{
+		"href http://site.test/img/sprite.svg#icon",
+		"href http://site.test/photo.jpg",
+		"url() http://site.test/img/filters.svg#blur",
+		"url() http://site.test/img/img/bg.png",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+}
+
+func TestReadPDF(t *testing.T) {
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write([]byte(strings.Repeat("<< /Type /Annot >>\n", 20) + `<< /Type /Annot /A << /S /URI /URI (http://packed.test/a\(1\).html) >> >>`))
+	w.Close()
+
+	pdf := "%PDF-1.7\n" +
+		"1 0 obj << /A << /S /URI /URI (/doc.html) >> >> endobj\n" +
+		"2 0 obj << /A << /S /URI /URI <687474703a2f2f6865782e746573742f> >> >> endobj\n" +
+		"3 0 obj << /Length 4 >>\nstream\nabcd\nendstream\nendobj\n" +
+		"4 0 obj << /Filter /FlateDecode >>\nstream\n" + z.String() + "\nendstream\nendobj\n%%EOF\n"
+	got := bodyLinks(t, "http://site.test/files/a.pdf", "application/pdf", pdf)
+	want := []string{
+		"/URI http://hex.test/",
+		"/URI http://packed.test/a(1).html",
+		"/URI http://site.test/doc.html",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+
+	// Конец потока не считается его началом:
+	if n := len(pdfStream.FindAllIndex([]byte(pdf), -1)); n != 2 {
+		t.Errorf("потоков: %v, want 2", n)
+	}
+}
+
+func TestReadFeed(t *testing.T) {
+	rss := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/"><channel>
+<link>http://site.test/</link>
+<image><url>/logo.png</url></image>
+<item>
+<link>/posts/1.html</link>
+<guid isPermaLink="false">tag:site.test,2024:1</guid>
+<comments>/posts/1.html#comments</comments>
+<enclosure url="/media/1.mp3" type="audio/mpeg"/>
+<media:content url="/media/1.jpg"/>
+</item>
+</channel></rss>`
+	got := bodyLinks(t, "http://site.test/feed.rss", "text/xml; charset=utf-8", rss)
+	want := []string{
+		" http://site.test/",
+		" http://site.test/logo.png",
+		" http://site.test/posts/1.html",
+		" http://site.test/posts/1.html#comments",
+		"url http://site.test/media/1.jpg",
+		"url http://site.test/media/1.mp3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RSS: links = %q, want %q", got, want)
+	}
+
+	atom := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<icon>/favicon.ico</icon>
+<link rel="self" href="/atom.xml"/>
+<entry>
+<id>urn:uuid:1</id>
+<link href="posts/2.html"/>
+<link rel="enclosure" href="/media/2.mp4" type="video/mp4"/>
+<content type="image/png" src="/media/2.png"/>
+</entry>
+</feed>`
+	got = bodyLinks(t, "http://site.test/blog/atom.xml", "text/xml; charset=utf-8", atom)
+	want = []string{
+		" http://site.test/favicon.ico",
+		"href http://site.test/atom.xml",
+		"href http://site.test/blog/posts/2.html",
+		"href http://site.test/media/2.mp4",
+		"src http://site.test/media/2.png",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Atom: links = %q, want %q", got, want)
+	}
+}
+
+func TestReadJSONLD(t *testing.T) {
+	page := `<!DOCTYPE html><html><head>
+<script type="application/ld+json">{
+	"@context": "https://schema.org",
+	"@type": "Article",
+	"url": "/article.html",
+	"image": ["/img/1.jpg", {"@type": "ImageObject", "contentUrl": "/img/2.jpg"}],
+	"publisher": {"logo": {"url": "/logo.png"}},
+	"name": "/not-a-link"
+}</script>
+</head><body></body></html>`
+	got := bodyLinks(t, "http://site.test/", "text/html; charset=utf-8", page)
+	want := []string{
+		"contentUrl http://site.test/img/2.jpg",
+		"image http://site.test/img/1.jpg",
+		"url http://site.test/article.html",
+		"url http://site.test/logo.png",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+}
+
+func TestReadWebManifest(t *testing.T) {
+	manifest := `{
+	"name": "App",
+	"start_url": "./?source=pwa",
+	"icons": [{"src": "icons/192.png", "sizes": "192x192"}, {"src": "/icons/512.png", "sizes": "512x512"}],
+	"screenshots": [{"src": "shot.png"}],
+	"shortcuts": [{"name": "New", "url": "/new", "icons": [{"src": "icons/new.png"}]}]
+}`
+	got := bodyLinks(t, "http://site.test/app/site.webmanifest", "text/plain; charset=utf-8", manifest)
+	want := []string{
+		"src http://site.test/app/icons/192.png",
+		"src http://site.test/app/icons/new.png",
+		"src http://site.test/app/shot.png",
+		"src http://site.test/icons/512.png",
+		"start_url http://site.test/app/?source=pwa",
+		"url http://site.test/new",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+
+	// JSON без полей манифеста - не манифест:
+	s := NewScanner()
+	if s.readWebManifest(Edge{}, []byte(`{"name": "x", "src": "/a.png"}`)) {
+		t.Error("JSON без start_url и icons принят за манифест")
+	}
+}
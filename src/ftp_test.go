@@ -0,0 +1,235 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Запустить минимальный FTP сервер с файлами в памяти.
+// Пути файлов абсолютные: "/pub/index.txt". Вход с паролем "bad"
+// запрещается. Адрес в ответе PASV намеренно недоступен: клиент
+// должен использовать хост управляющего соединения.
+func newFTPServer(t *testing.T, files map[string]string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	isDir := func(p string) bool {
+		p = strings.TrimSuffix(p, "/") + "/"
+		for k := range files {
+			if strings.HasPrefix(k, p) {
+				return true
+			}
+		}
+		return false
+	}
+	abs := func(cwd, p string) string {
+		if !strings.HasPrefix(p, "/") {
+			p = path.Join(cwd, p)
+		}
+		return p
+	}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		c := textproto.NewConn(conn)
+		c.PrintfLine("220 Test FTP server")
+		cwd := "/"
+		var pasv net.Listener
+		defer func() {
+			if pasv != nil {
+				pasv.Close()
+			}
+		}()
+
+		// Передать данные по пассивному соединению:
+		send := func(data string) {
+			c.PrintfLine("150 Opening data connection")
+			if d, err := pasv.Accept(); err == nil {
+				io.WriteString(d, data)
+				d.Close()
+			}
+			pasv.Close()
+			pasv = nil
+			c.PrintfLine("226 Transfer complete")
+		}
+
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "USER":
+				c.PrintfLine("331 Password required")
+			case "PASS":
+				if arg == "bad" {
+					c.PrintfLine("530 Login incorrect")
+				} else {
+					c.PrintfLine("230 Logged in")
+				}
+			case "TYPE":
+				c.PrintfLine("200 Type set")
+			case "EPSV":
+				c.PrintfLine("500 EPSV not understood")
+			case "PASV":
+				pasv, _ = net.Listen("tcp", "127.0.0.1:0")
+				p := pasv.Addr().(*net.TCPAddr).Port
+				c.PrintfLine("227 Entering Passive Mode (10,255,255,1,%d,%d)", p>>8, p&0xff)
+			case "CWD":
+				if p := abs(cwd, arg); isDir(p) {
+					cwd = p
+					c.PrintfLine("250 Directory changed")
+				} else {
+					c.PrintfLine("550 No such directory")
+				}
+			case "SIZE":
+				if v, ok := files[abs(cwd, arg)]; ok {
+					c.PrintfLine("213 %d", len(v))
+				} else {
+					c.PrintfLine("550 No such file")
+				}
+			case "NLST":
+				names := make([]string, 0)
+				seen := make(map[string]bool)
+				for k := range files {
+					if rest, ok := strings.CutPrefix(k, strings.TrimSuffix(cwd, "/")+"/"); ok {
+						name, _, _ := strings.Cut(rest, "/")
+						if !seen[name] {
+							seen[name] = true
+							names = append(names, name)
+						}
+					}
+				}
+				sort.Strings(names)
+				send(strings.Join(names, "\r\n") + "\r\n")
+			case "RETR":
+				if v, ok := files[abs(cwd, arg)]; ok {
+					send(v)
+				} else {
+					c.PrintfLine("550 No such file")
+				}
+			case "QUIT":
+				c.PrintfLine("221 Bye")
+				return
+			default:
+				c.PrintfLine("502 Command not implemented")
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestFTPFetcher(t *testing.T) {
+	addr := newFTPServer(t, map[string]string{
+		"/pub/index.txt":   "hello",
+		"/pub/docs/a.html": `<!DOCTYPE html><a href="b.css">b</a>`,
+		"/pub/docs/b.css":  `a{}`,
+	})
+	f := &FTPFetcher{}
+	get := func(method, u string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, u, nil)
+		resp, err := f.Fetch(req)
+		if err != nil {
+			t.Fatalf("%v %v: %v", method, u, err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("%v %v: %v", method, u, err)
+		}
+		return resp, string(body)
+	}
+	base := "ftp://" + addr
+
+	// Файл:
+	resp, body := get(http.MethodGet, base+"/pub/index.txt")
+	if resp.StatusCode != 200 || body != "hello" || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("файл: %v %q %q", resp.StatusCode, body, resp.Header.Get("Content-Type"))
+	}
+	resp, _ = get(http.MethodHead, base+"/pub/index.txt")
+	if resp.StatusCode != 200 || resp.ContentLength != 5 {
+		t.Errorf("HEAD файла: %v %v", resp.StatusCode, resp.ContentLength)
+	}
+
+	// Список файлов папки:
+	resp, body = get(http.MethodGet, base+"/pub/docs/")
+	if resp.StatusCode != 200 || resp.Request.URL.Path != "/pub/docs/" {
+		t.Errorf("папка: %v %v", resp.StatusCode, resp.Request.URL)
+	}
+	for _, want := range []string{`href="/pub/docs/a.html"`, `href="/pub/docs/b.css"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("в списке файлов нет %v: %q", want, body)
+		}
+	}
+
+	// Папка без "/" в конце:
+	resp, body = get(http.MethodGet, base+"/pub")
+	if resp.StatusCode != 200 || resp.Request.URL.String() != base+"/pub/" {
+		t.Errorf("папка без \"/\": %v %v", resp.StatusCode, resp.Request.URL)
+	}
+	if !strings.Contains(body, `href="/pub/docs"`) || !strings.Contains(body, `href="/pub/index.txt"`) {
+		t.Errorf("папка без \"/\": %q", body)
+	}
+
+	// Ошибки сервера:
+	for u, want := range map[string]int{
+		base + "/pub/none.txt":                     http.StatusNotFound,
+		"ftp://user:bad@" + addr + "/pub/":         http.StatusForbidden,
+		"ftp://user:bad@" + addr + "/pub/none.txt": http.StatusForbidden,
+	} {
+		if resp, _ := get(http.MethodGet, u); resp.StatusCode != want {
+			t.Errorf("%v: %v, want %v", u, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestScanFTP(t *testing.T) {
+	addr := newFTPServer(t, map[string]string{
+		"/pub/index.txt":   "hello",
+		"/pub/docs/a.html": `<!DOCTYPE html><a href="b.css">b</a><a href="c.css">c</a>`,
+		"/pub/docs/b.css":  `a{}`,
+	})
+	base := "ftp://" + addr
+
+	s := runScanner(t, ScannerParams{URL: base + "/pub", RepeatsMax: 1})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+	for name, want := range map[string]string{
+		"/pub/index.txt":  "hello",
+		"/pub/docs/b.css": "a{}",
+	} {
+		obj := findSource(t, s, base+name)
+		if v := sourceFile(t, obj); v != want {
+			t.Errorf("%v: содержимое %q", name, v)
+		}
+	}
+	if obj := findSource(t, s, base+"/pub/docs/c.css"); obj.Status() != 404 {
+		t.Errorf("нет файла: %v %v", obj.State(), obj.Status())
+	}
+	if obj := findSource(t, s, base+"/pub"); obj.FinalURL() == nil || obj.FinalURL().String() != base+"/pub/" {
+		t.Errorf("FinalURL() = %v", obj.FinalURL())
+	}
+}
@@ -1,5 +1,10 @@
 module github.com/VolkovRA/GoMirror
 
-go 1.18
+go 1.21
 
-require golang.org/x/net v0.0.0-20220425223048-2871e0cb64e4 // indirect
+require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/klauspost/compress v1.17.9
+	golang.org/x/net v0.0.0-20220425223048-2871e0cb64e4
+	golang.org/x/text v0.3.7
+)
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Ссылка между ресурсами.
+// Ребро графа ссылок: ресурс From содержит ссылку на ресурс To.
+type Edge struct {
+	From *Source // Ресурс, содержащий ссылку
+	To   *Source // Ресурс, на который указывает ссылка
+	Tag  string  // Тег со ссылкой: "a", "img", ... Пустой для текстовых ресурсов
+	Attr string  // Атрибут тега со ссылкой: "href", "src", ... или способ поиска: "url()", "text"
+}
+
+// Формат выгрузки графа ссылок.
+type GraphFormat int
+
+const (
+
+	// Граф в формате Graphviz DOT
+	GraphDOT GraphFormat = iota
+
+	// Граф в формате GraphML
+	GraphML
+
+	// Граф в формате JSON: списки узлов и рёбер
+	GraphJSON
+)
+
+// Получить текстовое представление формата графа.
+func (v GraphFormat) String() string {
+	switch v {
+	case GraphDOT:
+		return "DOT"
+	case GraphML:
+		return "GraphML"
+	case GraphJSON:
+		return "JSON"
+	default:
+		return "Unknown"
+	}
+}
+
+// Получить расширение файла для формата графа.
+func (v GraphFormat) Ext() string {
+	switch v {
+	case GraphDOT:
+		return "dot"
+	case GraphML:
+		return "graphml"
+	case GraphJSON:
+		return "json"
+	default:
+		return "txt"
+	}
+}
+
+// Добавить ребро в граф без повторов и пересчитать глубину.
+// п.с. Список с Lock()
+func (s *Sources) link(e *Edge) {
+	for _, v := range e.To.in {
+		if v.From == e.From && v.Tag == e.Tag && v.Attr == e.Attr {
+			return
+		}
+	}
+
+	e.To.in = append(e.To.in, e)
+	e.From.out = append(e.From.out, e)
+	if e.From.depth >= 0 {
+		s.relax(e.To, e.From.depth+1)
+	}
+}
+
+// Уменьшить глубину ресурса и всех его потомков, если найден
+// более короткий путь от исходного URL.
+// п.с. Список с Lock()
+func (s *Sources) relax(obj *Source, depth int) {
+	if obj.depth >= 0 && obj.depth <= depth {
+		return
+	}
+
+	obj.depth = depth
+	queue := []*Source{obj}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, e := range v.out {
+			if e.To.depth < 0 || e.To.depth > v.depth+1 {
+				e.To.depth = v.depth + 1
+				queue = append(queue, e.To)
+			}
+		}
+	}
+}
+
+// Входящие ссылки на ресурс.
+// Возвращает копию, безопасную для внесения изменений.
+func (s *Source) Inbound() []Edge {
+	s.list.mu.RLock()
+	defer s.list.mu.RUnlock()
+
+	a2 := make([]Edge, len(s.in))
+	for i, e := range s.in {
+		a2[i] = *e
+	}
+
+	return a2
+}
+
+// Исходящие ссылки из ресурса.
+// Возвращает копию, безопасную для внесения изменений.
+func (s *Source) Outbound() []Edge {
+	s.list.mu.RLock()
+	defer s.list.mu.RUnlock()
+
+	a2 := make([]Edge, len(s.out))
+	for i, e := range s.out {
+		a2[i] = *e
+	}
+
+	return a2
+}
+
+// Глубина ресурса - минимальное кол-во переходов по ссылкам
+// от исходного URL. Для исходного URL и корневых файлов: 0.
+// Значение может уменьшаться по ходу сканирования, если будет
+// найден более короткий путь.
+func (s *Source) Depth() int {
+	s.list.mu.RLock()
+	defer s.list.mu.RUnlock()
+	return s.depth
+}
+
+// Получить список всех найденных ресурсов.
+// Полученный список безопасен для внесения изменений.
+func (s *Scanner) Sources() []*Source {
+	s.mu.RLock()
+	src := s.sources
+	s.mu.RUnlock()
+	if src == nil {
+		return nil
+	}
+	return src.List()
+}
+
+// Получить ресурс по URL.
+// Возвращает nil, если ресурс с таким адресом не найден.
+func (s *Scanner) Source(url string) *Source {
+	s.mu.RLock()
+	src := s.sources
+	s.mu.RUnlock()
+	if src == nil {
+		return nil
+	}
+
+	src.mu.RLock()
+	defer src.mu.RUnlock()
+	return src.m[url]
+}
+
+// Получить список осиротевших страниц.
+//
+// Это внутренние HTML страницы, на которые не ссылается ни одна
+// другая страница сайта. Такие страницы найдены только через
+// исходный URL, robots.txt, sitemap.xml или текстовые ресурсы.
+func (s *Scanner) Orphans() []*Source {
+	res := make([]*Source, 0)
+	for _, obj := range s.Sources() {
+		if obj.IsExternal() || !strings.Contains(obj.Mime(), "text/html") {
+			continue
+		}
+
+		in := obj.Inbound()
+		if len(in) == 0 {
+			continue // Исходный URL
+		}
+
+		orphan := true
+		for _, e := range in {
+			if e.From != obj && e.Tag != "" && strings.Contains(e.From.Mime(), "text/html") {
+				orphan = false
+				break
+			}
+		}
+		if orphan {
+			res = append(res, obj)
+		}
+	}
+
+	return res
+}
+
+// Записать граф ссылок в указанном формате.
+func (s *Scanner) ExportGraph(w io.Writer, format GraphFormat) error {
+	switch format {
+	case GraphDOT:
+		return s.ExportGraphDOT(w)
+	case GraphML:
+		return s.ExportGraphML(w)
+	case GraphJSON:
+		return s.ExportGraphJSON(w)
+	default:
+		return fmt.Errorf("Неизвестный формат графа: %v", format)
+	}
+}
+
+// Сохранить граф ссылок в файл.
+func (s *Scanner) ExportGraphFile(path string, format GraphFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Не удалось создать файл графа: \"%v\": %w", path, err)
+	}
+
+	err = s.ExportGraph(f, format)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return fmt.Errorf("Не удалось записать граф %v в файл: \"%v\": %w", format, path, err)
+	}
+
+	return nil
+}
+
+// Узел графа для выгрузки
+type graphNode struct {
+	ID       int    `json:"id"`
+	URL      string `json:"url"`
+	State    string `json:"state"`
+	Mime     string `json:"mime"`
+	External bool   `json:"external"`
+	Depth    int    `json:"depth"`
+	In       int    `json:"in"`
+	Out      int    `json:"out"`
+}
+
+// Ребро графа для выгрузки
+type graphEdge struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Tag  string `json:"tag,omitempty"`
+	Attr string `json:"attr"`
+}
+
+// Получить снимок графа ссылок для выгрузки.
+// Идентификаторы узлов - порядковые номера ресурсов.
+func (s *Scanner) graph() ([]graphNode, []graphEdge) {
+	a := s.Sources()
+	ids := make(map[*Source]int, len(a))
+	for i, obj := range a {
+		ids[obj] = i
+	}
+
+	nodes := make([]graphNode, len(a))
+	edges := make([]graphEdge, 0, len(a))
+	for i, obj := range a {
+		obj.mu.RLock()
+		nodes[i] = graphNode{
+			ID:       i,
+			URL:      obj.url.String(),
+			State:    obj.state.Code(),
+			Mime:     obj.mime,
+			External: obj.isExternal,
+		}
+		obj.mu.RUnlock()
+
+		obj.list.mu.RLock()
+		nodes[i].Depth = obj.depth
+		nodes[i].In = len(obj.in)
+		nodes[i].Out = len(obj.out)
+		for _, e := range obj.out {
+			to, ok := ids[e.To]
+			if !ok {
+				continue // Добавлен после снимка списка
+			}
+			edges = append(edges, graphEdge{From: i, To: to, Tag: e.Tag, Attr: e.Attr})
+		}
+		obj.list.mu.RUnlock()
+	}
+
+	return nodes, edges
+}
+
+// Записать граф ссылок в формате JSON.
+func (s *Scanner) ExportGraphJSON(w io.Writer) error {
+	nodes, edges := s.graph()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(struct {
+		Nodes []graphNode `json:"nodes"`
+		Edges []graphEdge `json:"edges"`
+	}{
+		Nodes: nodes,
+		Edges: edges,
+	})
+}
+
+// Записать граф ссылок в формате Graphviz DOT.
+func (s *Scanner) ExportGraphDOT(w io.Writer) error {
+	nodes, edges := s.graph()
+
+	var b strings.Builder
+	b.WriteString("digraph site {\n")
+	for _, n := range nodes {
+		shape := "ellipse"
+		if n.External {
+			shape = "box"
+		}
+		fmt.Fprintf(&b, "\tn%v [label=%v, shape=%v, depth=%v];\n", n.ID, strconv.Quote(n.URL), shape, n.Depth)
+	}
+	for _, e := range edges {
+		label := e.Attr
+		if e.Tag != "" {
+			label = e.Tag + " " + e.Attr
+		}
+		fmt.Fprintf(&b, "\tn%v -> n%v [label=%v];\n", e.From, e.To, strconv.Quote(label))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Записать граф ссылок в формате GraphML.
+func (s *Scanner) ExportGraphML(w io.Writer) error {
+	nodes, edges := s.graph()
+
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	type key struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+	type node struct {
+		ID   string `xml:"id,attr"`
+		Data []data `xml:"data"`
+	}
+	type edge struct {
+		Source string `xml:"source,attr"`
+		Target string `xml:"target,attr"`
+		Data   []data `xml:"data"`
+	}
+	type graphml struct {
+		XMLName xml.Name `xml:"graphml"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		Keys    []key    `xml:"key"`
+		Graph   struct {
+			ID          string `xml:"id,attr"`
+			EdgeDefault string `xml:"edgedefault,attr"`
+			Nodes       []node `xml:"node"`
+			Edges       []edge `xml:"edge"`
+		} `xml:"graph"`
+	}
+
+	doc := graphml{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []key{
+			{ID: "url", For: "node", Name: "url", Type: "string"},
+			{ID: "state", For: "node", Name: "state", Type: "string"},
+			{ID: "mime", For: "node", Name: "mime", Type: "string"},
+			{ID: "external", For: "node", Name: "external", Type: "boolean"},
+			{ID: "depth", For: "node", Name: "depth", Type: "int"},
+			{ID: "tag", For: "edge", Name: "tag", Type: "string"},
+			{ID: "attr", For: "edge", Name: "attr", Type: "string"},
+		},
+	}
+	doc.Graph.ID = "site"
+	doc.Graph.EdgeDefault = "directed"
+	for _, n := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node{
+			ID: "n" + strconv.Itoa(n.ID),
+			Data: []data{
+				{Key: "url", Value: n.URL},
+				{Key: "state", Value: n.State},
+				{Key: "mime", Value: n.Mime},
+				{Key: "external", Value: strconv.FormatBool(n.External)},
+				{Key: "depth", Value: strconv.Itoa(n.Depth)},
+			},
+		})
+	}
+	for _, e := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, edge{
+			Source: "n" + strconv.Itoa(e.From),
+			Target: "n" + strconv.Itoa(e.To),
+			Data: []data{
+				{Key: "tag", Value: e.Tag},
+				{Key: "attr", Value: e.Attr},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,756 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Настройки извлечения ссылок из JavaScript.
+//
+// Ссылки ищутся по токенам кода, а не по тексту: строки в комментариях
+// и регулярных выражениях не считаются ссылками. Всегда извлекаются
+// статические и динамические импорты, new URL(..., import.meta.url),
+// абсолютные адреса в строках и комментарии sourceMappingURL.
+// Остальное - эвристики, которые можно настроить для сайта.
+type JSParams struct {
+
+	// Функции, первый строковый аргумент которых - ссылка.
+	// Имя может быть составным: "serviceWorker.register".
+	// Для importScripts() ссылками считаются все строковые аргументы.
+	Calls []string
+
+	// Искать строки, похожие на пути к файлам с расширением из
+	// PathExts: "/api/data.json", "./chunk.js", "assets/index-4f2a.js"
+	// Путь должен содержать "/". Так находятся ресурсы из списков
+	// зависимостей Vite и других сборщиков.
+	Paths bool
+
+	// Расширения файлов для поиска путей, без точки: "js", "css", ...
+	PathExts []string
+
+	// Разбирать карты чанков webpack: выражения вида
+	// "static/js/" + id + "." + {1:"a1b2"}[id] + ".chunk.js"
+	// Для каждого чанка из карты вычисляется путь к его файлу.
+	Chunks bool
+}
+
+// Получить настройки извлечения ссылок из JavaScript по умолчанию.
+func DefaultJSParams() JSParams {
+	return JSParams{
+		Calls:    []string{"fetch", "importScripts", "Worker", "SharedWorker", "serviceWorker.register", "Audio", "EventSource"},
+		Paths:    true,
+		PathExts: []string{"js", "mjs", "css", "json", "wasm", "map", "png", "jpg", "jpeg", "gif", "svg", "webp", "avif", "ico", "woff", "woff2", "ttf", "otf", "mp3", "mp4", "webm", "html"},
+		Chunks:   true,
+	}
+}
+
+// Получить настройки извлечения ссылок из JavaScript для сканирования.
+func (s *Scanner) jsParams() *JSParams {
+	if s.params.JS != nil {
+		return s.params.JS
+	}
+	return &defaultJSParams
+}
+
+// Настройки по умолчанию для внутреннего использования
+var defaultJSParams = DefaultJSParams()
+
+// Вид токена JavaScript
+type jsKind int
+
+const (
+	jsIdent    jsKind = iota // Идентификатор или ключевое слово
+	jsPunct                  // Знак пунктуации, всегда один символ
+	jsString                 // Строка или шаблон без подстановок
+	jsTemplate               // Часть шаблона с подстановками
+	jsNumber                 // Число
+	jsRegexp                 // Регулярное выражение
+	jsComment                // Комментарий
+)
+
+// Токен JavaScript
+type jsToken struct {
+	kind jsKind
+	val  string // Значение: для строк - без кавычек и с обработанными escape
+	pos  int    // Позиция в исходном коде, байт
+}
+
+// Ключевые слова, после которых "/" начинает регулярное выражение
+var jsRegexpAfter = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true,
+	"delete": true, "void": true, "throw": true, "case": true, "do": true, "else": true,
+	"yield": true, "await": true,
+}
+
+// Разбить JavaScript код на токены.
+// Разбор нестрогий: некорректный код не приводит к ошибке,
+// незакрытые строки и комментарии заканчиваются концом кода.
+func jsTokenize(src []byte) []jsToken {
+	var res []jsToken
+	var braces []bool // Стек фигурных скобок: true - подстановка в шаблоне ${}
+	var prev *jsToken // Последний значимый токен
+
+	add := func(t jsToken) {
+		res = append(res, t)
+		if t.kind != jsComment {
+			prev = &res[len(res)-1]
+		}
+	}
+
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c <= ' ':
+			i++
+
+		// Комментарии:
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			j := i + 2
+			for j < len(src) && src[j] != '\n' && src[j] != '\r' {
+				j++
+			}
+			add(jsToken{kind: jsComment, val: string(src[i+2 : j]), pos: i})
+			i = j
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			j := i + 2
+			for j+1 < len(src) && !(src[j] == '*' && src[j+1] == '/') {
+				j++
+			}
+			end := min(j+2, len(src))
+			add(jsToken{kind: jsComment, val: string(src[i+2 : min(j, len(src))]), pos: i})
+			i = end
+
+		// Регулярное выражение:
+		case c == '/' && jsRegexpAllowed(prev):
+			if j := jsRegexpEnd(src, i); j > 0 {
+				add(jsToken{kind: jsRegexp, val: string(src[i:j]), pos: i})
+				i = j
+			} else {
+				add(jsToken{kind: jsPunct, val: "/", pos: i})
+				i++
+			}
+
+		// Строки:
+		case c == '"' || c == '\'':
+			val, j := jsQuoted(src, i)
+			add(jsToken{kind: jsString, val: val, pos: i})
+			i = j
+		case c == '`':
+			kind, val, j, open := jsTemplateChars(src, i+1)
+			if open {
+				braces = append(braces, true)
+			}
+			add(jsToken{kind: kind, val: val, pos: i})
+			i = j
+
+		// Фигурные скобки и продолжение шаблона после подстановки:
+		case c == '{':
+			braces = append(braces, false)
+			add(jsToken{kind: jsPunct, val: "{", pos: i})
+			i++
+		case c == '}':
+			tmpl := len(braces) > 0 && braces[len(braces)-1]
+			if len(braces) > 0 {
+				braces = braces[:len(braces)-1]
+			}
+			if !tmpl {
+				add(jsToken{kind: jsPunct, val: "}", pos: i})
+				i++
+				break
+			}
+			_, val, j, open := jsTemplateChars(src, i+1)
+			if open {
+				braces = append(braces, true)
+			}
+			add(jsToken{kind: jsTemplate, val: val, pos: i})
+			i = j
+
+		// Идентификаторы и числа:
+		case isJSIdent(c) || c >= utf8.RuneSelf:
+			j := i + 1
+			for j < len(src) && (isJSIdent(src[j]) || (src[j] >= '0' && src[j] <= '9') || src[j] >= utf8.RuneSelf) {
+				j++
+			}
+			add(jsToken{kind: jsIdent, val: string(src[i:j]), pos: i})
+			i = j
+		case (c >= '0' && c <= '9') || (c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9'):
+			j := i + 1
+			for j < len(src) {
+				d := src[j]
+				if (d == '+' || d == '-') && (src[j-1] == 'e' || src[j-1] == 'E') && !strings.ContainsAny(string(src[i:j]), "xXbBoO") {
+					j++
+					continue
+				}
+				if !(isJSIdent(d) || (d >= '0' && d <= '9') || d == '.') {
+					break
+				}
+				j++
+			}
+			add(jsToken{kind: jsNumber, val: string(src[i:j]), pos: i})
+			i = j
+
+		default:
+			add(jsToken{kind: jsPunct, val: string(c), pos: i})
+			i++
+		}
+	}
+
+	return res
+}
+
+// Символ идентификатора JavaScript, кроме цифр
+func isJSIdent(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == '$' || c == '#'
+}
+
+// Может ли "/" после токена prev начинать регулярное выражение.
+// После значения - это деление, после оператора - выражение.
+func jsRegexpAllowed(prev *jsToken) bool {
+	if prev == nil {
+		return true
+	}
+	switch prev.kind {
+	case jsIdent:
+		return jsRegexpAfter[prev.val]
+	case jsPunct:
+		return prev.val != ")" && prev.val != "]"
+	default:
+		return false
+	}
+}
+
+// Найти конец регулярного выражения, начинающегося в позиции i.
+// Возвращает -1, если выражение не закрыто до конца строки.
+func jsRegexpEnd(src []byte, i int) int {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '\n', '\r':
+			return -1
+		case '/':
+			if class {
+				continue
+			}
+			j++
+			for j < len(src) && isJSIdent(src[j]) {
+				j++
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+// Прочитать строку в кавычках, начинающуюся в позиции i.
+// Возвращает значение строки и позицию после неё.
+func jsQuoted(src []byte, i int) (string, int) {
+	q := src[i]
+	var b strings.Builder
+	j := i + 1
+	for j < len(src) && src[j] != q && src[j] != '\n' {
+		if src[j] == '\\' {
+			j = jsEscape(src, j, &b)
+			continue
+		}
+		b.WriteByte(src[j])
+		j++
+	}
+	if j < len(src) && src[j] == q {
+		j++
+	}
+	return b.String(), j
+}
+
+// Прочитать символы шаблонной строки с позиции i до "`" или "${".
+// Возвращает jsString для шаблона без подстановок, иначе jsTemplate,
+// и open = true, если чтение остановилось на открытии подстановки "${",
+// а не на конце шаблона.
+func jsTemplateChars(src []byte, i int) (kind jsKind, val string, end int, open bool) {
+	var b strings.Builder
+	j := i
+	for j < len(src) {
+		switch {
+		case src[j] == '\\':
+			j = jsEscape(src, j, &b)
+		case src[j] == '`':
+			if i > 0 && src[i-1] == '`' {
+				return jsString, b.String(), j + 1, false
+			}
+			return jsTemplate, b.String(), j + 1, false
+		case src[j] == '$' && j+1 < len(src) && src[j+1] == '{':
+			return jsTemplate, b.String(), j + 2, true
+		default:
+			b.WriteByte(src[j])
+			j++
+		}
+	}
+	return jsTemplate, b.String(), j, false
+}
+
+// Обработать escape последовательность в строке с позиции "\".
+// Пишет символ в b и возвращает позицию после последовательности.
+func jsEscape(src []byte, i int, b *strings.Builder) int {
+	if i+1 >= len(src) {
+		return i + 1
+	}
+	c := src[i+1]
+	switch c {
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case '\n':
+		// Продолжение строки
+	case 'x':
+		if i+4 <= len(src) {
+			if v, err := strconv.ParseUint(string(src[i+2:i+4]), 16, 8); err == nil {
+				b.WriteRune(rune(v))
+				return i + 4
+			}
+		}
+		b.WriteByte(c)
+	case 'u':
+		hex, end := "", i+2
+		if i+2 < len(src) && src[i+2] == '{' {
+			if k := strings.IndexByte(string(src[i+2:]), '}'); k > 0 {
+				hex, end = string(src[i+3:i+2+k]), i+3+k
+			}
+		} else if i+6 <= len(src) {
+			hex, end = string(src[i+2:i+6]), i+6
+		}
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil && hex != "" {
+			b.WriteRune(rune(v))
+			return end
+		}
+		b.WriteByte(c)
+	default:
+		b.WriteByte(c)
+	}
+	return i + 2
+}
+
+// Проверить, является ли ресурс JavaScript кодом:
+// по заголовку Content-Type или расширению файла.
+func isScript(obj *Source) bool {
+	obj.mu.RLock()
+	typ := strings.ToLower(obj.header.Get("Content-Type"))
+	ext := strings.ToLower(path.Ext(obj.url.Path))
+	obj.mu.RUnlock()
+
+	return strings.Contains(typ, "javascript") || strings.Contains(typ, "ecmascript") ||
+		ext == ".js" || ext == ".mjs" || ext == ".cjs"
+}
+
+// Комментарий со ссылкой на карту исходного кода:
+// //# sourceMappingURL=app.js.map
+var jsSourceMap = regexp.MustCompile(`^[#@]\s*sourceMappingURL=(\S+)`)
+
+// Найти ссылки в JavaScript коде.
+// Относительные ссылки разрешаются от адреса ресурса с кодом:
+// для встроенного скрипта это адрес страницы.
+func (s *Scanner) readJS(e Edge, body []byte) {
+	p := s.jsParams()
+	all := jsTokenize(body)
+
+	// Значимые токены без комментариев:
+	t := make([]jsToken, 0, len(all))
+	for _, v := range all {
+		if v.kind != jsComment {
+			t = append(t, v)
+			continue
+		}
+		if m := jsSourceMap.FindStringSubmatch(strings.TrimSpace(v.val)); m != nil {
+			s.followJS(e, "sourceMappingURL", m[1], false)
+		}
+	}
+
+	is := func(i int, kind jsKind, val string) bool {
+		return i >= 0 && i < len(t) && t[i].kind == kind && t[i].val == val
+	}
+	str := func(i int) bool {
+		return i >= 0 && i < len(t) && t[i].kind == jsString
+	}
+
+	used := make(map[int]bool) // Строки, уже обработанные как ссылки
+	link := func(i int, attr string) {
+		used[i] = true
+		s.followJS(e, attr, t[i].val, false)
+	}
+
+	stmt := false // Внутри инструкции import или export
+	for i := 0; i < len(t); i++ {
+		v := t[i]
+		if v.kind == jsPunct && v.val == ";" {
+			stmt = false
+			continue
+		}
+		if v.kind != jsIdent || is(i-1, jsPunct, ".") {
+			continue
+		}
+
+		switch v.val {
+		case "import":
+			switch {
+			case is(i+1, jsPunct, "(") && str(i+2):
+				link(i+2, "import()")
+			case str(i + 1):
+				link(i+1, "import")
+			case is(i+1, jsPunct, "."):
+			default:
+				stmt = true
+			}
+		case "export":
+			stmt = true
+		case "from":
+			if stmt && str(i+1) {
+				link(i+1, "import")
+				stmt = false
+			}
+		case "new":
+			// new URL("./img.png", import.meta.url)
+			if is(i+1, jsIdent, "URL") && is(i+2, jsPunct, "(") && str(i+3) && is(i+4, jsPunct, ",") &&
+				is(i+5, jsIdent, "import") && is(i+6, jsPunct, ".") && is(i+7, jsIdent, "meta") &&
+				is(i+8, jsPunct, ".") && is(i+9, jsIdent, "url") {
+				link(i+3, "new URL()")
+			}
+		}
+	}
+
+	// Вызовы функций со ссылкой в аргументе:
+	for _, name := range p.Calls {
+		parts := strings.Split(name, ".")
+		last := parts[len(parts)-1]
+		for i := 0; i < len(t); i++ {
+			if !is(i, jsIdent, last) || !is(i+1, jsPunct, "(") || !str(i+2) {
+				continue
+			}
+			ok := true
+			for k := 1; k < len(parts) && ok; k++ {
+				ok = is(i-2*k+1, jsPunct, ".") && is(i-2*k, jsIdent, parts[len(parts)-1-k])
+			}
+			if !ok {
+				continue
+			}
+			attr := last + "()"
+			link(i+2, attr)
+			for j := i + 3; last == "importScripts" && is(j, jsPunct, ",") && str(j+1); j += 2 {
+				link(j+1, attr)
+			}
+		}
+	}
+
+	// Карты чанков:
+	if p.Chunks {
+		for _, v := range jsChunks(t) {
+			s.followJS(e, "chunk", v, true)
+		}
+	}
+
+	// Остальные строки:
+	for i, v := range t {
+		if v.kind != jsString || used[i] {
+			continue
+		}
+		if strings.HasPrefix(v.val, "http://") || strings.HasPrefix(v.val, "https://") || (strings.HasPrefix(v.val, "//") && len(v.val) > 2) {
+			if !strings.ContainsAny(v.val, " \t\n\"'<>") {
+				s.followJS(e, "string", v.val, false)
+			}
+		} else if p.Paths && s.jsPath(p, v.val) {
+			s.followJS(e, "string", v.val, !strings.HasPrefix(v.val, "."))
+		}
+	}
+}
+
+// Проверить, похожа ли строка на путь к файлу: "/a/b.js", "./c.css"
+func (s *Scanner) jsPath(p *JSParams, v string) bool {
+	if !strings.Contains(v, "/") || !jsPathChars.MatchString(v) {
+		return false
+	}
+	if i := strings.IndexAny(v, "?#"); i != -1 {
+		v = v[:i]
+	}
+	ext := strings.TrimPrefix(path.Ext(v), ".")
+	for _, e := range p.PathExts {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Допустимые символы пути в строке JavaScript
+var jsPathChars = regexp.MustCompile(`^(\.{1,2}/|/)?[A-Za-z0-9_\-.~@%+/]+[A-Za-z0-9_\-~@%+]\.[A-Za-z0-9]+([?#][^\s"'<>]*)?$`)
+
+// Запустить сканирование ссылки из JavaScript.
+// Если root установлен, путь без "/" разрешается от корня сайта
+// (Так работают публичный путь сборщиков: webpack, Vite), иначе -
+// от адреса ресурса с кодом.
+func (s *Scanner) followJS(e Edge, attr string, v string, root bool) {
+	u, err := url.Parse(v)
+	if err != nil || v == "" {
+		s.log.Debug("Ошибка разбора ссылки в JavaScript", "value", v, "err", err)
+		return
+	}
+	if u.Scheme == "data" || u.Scheme == "blob" || u.Scheme == "javascript" {
+		return
+	}
+
+	e.Attr = attr
+	if u.IsAbs() {
+		s.follow(e, u)
+		return
+	}
+	base := s.url
+	if !root && e.From != nil && e.From.url != nil {
+		base = e.From.url
+	}
+	if root && !strings.HasPrefix(u.Path, "/") && u.Host == "" {
+		u.Path = "/" + u.Path
+	}
+	s.follow(e, base.ResolveReference(u))
+}
+
+// Найти карты чанков webpack и вычислить пути к файлам чанков.
+//
+// Карта - объект со строковыми значениями, из которого берётся
+// значение по переменной: {12:"a1b2",34:"c3d4"}[id]. Выражение
+// конкатенации вокруг карты вычисляется для каждого ключа карты.
+// Публичный путь __webpack_require__.p считается пустым.
+func jsChunks(t []jsToken) []string {
+	var res []string
+	seen := make(map[int]bool) // Начала уже вычисленных выражений
+	for i := range t {
+		m, end := jsMap(t, i)
+		if m == nil || !(end+2 < len(t) && t[end].val == "[" && t[end+1].kind == jsIdent && t[end+2].val == "]") {
+			continue
+		}
+		id := t[end+1].val
+
+		// Границы выражения:
+		a := jsExprStart(t, i)
+		b := jsExprEnd(t, a)
+		if seen[a] || b <= a {
+			continue
+		}
+		seen[a] = true
+
+		// Все ключи всех карт в выражении:
+		keys := make([]string, 0)
+		has := make(map[string]bool)
+		for j := a; j < b; j++ {
+			if m, _ := jsMap(t, j); m != nil {
+				for k := range m {
+					if !has[k] {
+						has[k] = true
+						keys = append(keys, k)
+					}
+				}
+			}
+		}
+
+		for _, k := range keys {
+			v, ok := jsEval(t[a:b], id, k)
+			if ok && strings.Contains(v, ".") && !strings.ContainsAny(v, " \t\n\"'<>") {
+				res = append(res, v)
+			}
+		}
+	}
+
+	return res
+}
+
+// Прочитать объект со строковыми значениями, начинающийся в позиции i:
+// {12:"a1b2","vendors":"c3d4"}. Возвращает nil, если это не такой объект.
+// Вторым значением возвращает позицию после объекта.
+func jsMap(t []jsToken, i int) (map[string]string, int) {
+	if i >= len(t) || t[i].kind != jsPunct || t[i].val != "{" {
+		return nil, i
+	}
+	m := make(map[string]string)
+	j := i + 1
+	for j+2 < len(t) {
+		k, c, v := t[j], t[j+1], t[j+2]
+		if (k.kind != jsIdent && k.kind != jsString && k.kind != jsNumber) || c.val != ":" || v.kind != jsString {
+			return nil, i
+		}
+		m[k.val] = v.val
+		j += 3
+		if j < len(t) && t[j].val == "," {
+			j++
+		}
+		if j < len(t) && t[j].kind == jsPunct && t[j].val == "}" {
+			if len(m) == 0 {
+				return nil, i
+			}
+			return m, j + 1
+		}
+	}
+	return nil, i
+}
+
+// Найти начало выражения, содержащего токен i.
+// Группирующие скобки включаются в выражение, скобки вызова - нет.
+func jsExprStart(t []jsToken, i int) int {
+	depth := 0
+	for j := i - 1; j >= 0; j-- {
+		v := t[j]
+		if v.kind == jsPunct {
+			switch v.val {
+			case ")", "]", "}":
+				depth++
+				continue
+			case "(":
+				if depth > 0 {
+					depth--
+					continue
+				}
+				// Скобка вызова: f(...)
+				if j > 0 && (t[j-1].kind == jsIdent || t[j-1].val == ")" || t[j-1].val == "]") && !jsRegexpAfter[t[j-1].val] {
+					return j + 1
+				}
+				continue
+			case "[", "{":
+				if depth > 0 {
+					depth--
+					continue
+				}
+				return j + 1
+			case "+", ".", "|":
+				continue
+			}
+			if depth == 0 {
+				return j + 1
+			}
+		} else if v.kind == jsIdent && depth == 0 && jsRegexpAfter[v.val] {
+			return j + 1
+		}
+	}
+	return 0
+}
+
+// Найти конец выражения, начинающегося в позиции a.
+func jsExprEnd(t []jsToken, a int) int {
+	depth := 0
+	for j := a; j < len(t); j++ {
+		v := t[j]
+		if v.kind != jsPunct {
+			continue
+		}
+		switch v.val {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			if depth == 0 {
+				return j
+			}
+			depth--
+		case ",", ";", ":", "?", "=":
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(t)
+}
+
+// Вычислить выражение конкатенации строк для ключа карты чанков.
+// Поддерживаются строки, переменная id, карты по id, публичный
+// путь "x.p", группирующие скобки и альтернатива: (a || b)
+func jsEval(t []jsToken, id string, key string) (string, bool) {
+	// Внешние скобки:
+	for len(t) >= 2 && t[0].val == "(" && t[0].kind == jsPunct && jsClose(t, 0) == len(t)-1 {
+		t = t[1 : len(t)-1]
+	}
+	if len(t) == 0 {
+		return "", false
+	}
+
+	// Разбиваем по "+" и "||" на верхнем уровне:
+	var parts [][]jsToken
+	depth, start := 0, 0
+	for j, v := range t {
+		if v.kind != jsPunct {
+			continue
+		}
+		switch v.val {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case "+":
+			if depth == 0 {
+				parts = append(parts, t[start:j])
+				start = j + 1
+			}
+		case "|":
+			if depth == 0 && j+1 < len(t) && t[j+1].val == "|" {
+				left, ok := jsEval(t[:j], id, key)
+				if ok {
+					return left, true
+				}
+				return jsEval(t[j+2:], id, key)
+			}
+		}
+	}
+	parts = append(parts, t[start:])
+	if len(parts) > 1 {
+		var b strings.Builder
+		for _, p := range parts {
+			v, ok := jsEval(p, id, key)
+			if !ok {
+				return "", false
+			}
+			b.WriteString(v)
+		}
+		return b.String(), true
+	}
+
+	// Одиночный операнд:
+	switch {
+	case len(t) == 1 && t[0].kind == jsString:
+		return t[0].val, true
+	case len(t) == 1 && t[0].kind == jsIdent && t[0].val == id:
+		return key, true
+	case len(t) >= 3 && t[len(t)-2].val == "." && t[len(t)-1].val == "p" && t[len(t)-3].kind == jsIdent:
+		return "", true
+	}
+	if m, end := jsMap(t, 0); m != nil && end+3 == len(t) && t[end].val == "[" && t[end+1].val == id {
+		v, ok := m[key]
+		return v, ok
+	}
+
+	return "", false
+}
+
+// Найти закрывающую скобку для открывающей в позиции i.
+func jsClose(t []jsToken, i int) int {
+	depth := 0
+	for j := i; j < len(t); j++ {
+		if t[j].kind != jsPunct {
+			continue
+		}
+		switch t[j].val {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
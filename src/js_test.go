@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// Собрать ссылки, найденные парсером, без сканирования.
+// Функция read вызывает метод чтения для документа по адресу base.
+func collectLinks(t *testing.T, base string, read func(s *Scanner, obj *Source)) []string {
+	t.Helper()
+	u, err := url.Parse(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScanner()
+	s.url = u
+	links := make([]string, 0)
+	s.visit = func(e Edge, u *url.URL) {
+		if u != nil {
+			links = append(links, e.Attr+" "+u.String())
+		}
+	}
+	read(s, &Source{url: u})
+	return links
+}
+
+// Ссылки в JavaScript коде: атрибут и адрес.
+func jsLinks(t *testing.T, src string) []string {
+	t.Helper()
+	return collectLinks(t, "http://site.test/js/app.js", func(s *Scanner, obj *Source) {
+		s.readJS(Edge{From: obj}, []byte(src))
+	})
+}
+
+func TestJSTokenizeTemplates(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []jsKind
+	}{
+		{"без подстановок", "`a`;", []jsKind{jsString, jsPunct}},
+		{"подстановка", "`a${b}c`;", []jsKind{jsTemplate, jsIdent, jsTemplate, jsPunct}},
+		{"две подстановки", "`${a}-${b}`", []jsKind{jsTemplate, jsIdent, jsTemplate, jsIdent, jsTemplate}},
+		{"объект в подстановке", "`${ {a:1}.a }`", []jsKind{jsTemplate, jsPunct, jsIdent, jsPunct, jsNumber, jsPunct, jsPunct, jsIdent, jsTemplate}},
+		{"вложенный шаблон", "`a${`b${c}d`}e`", []jsKind{jsTemplate, jsTemplate, jsIdent, jsTemplate, jsTemplate}},
+		{"шаблон в блоке", "{ `x${1}y`; }", []jsKind{jsPunct, jsTemplate, jsNumber, jsTemplate, jsPunct, jsPunct}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []jsKind
+			for _, v := range jsTokenize([]byte(tt.src)) {
+				got = append(got, v.kind)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jsTokenize(%q) = %v, want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSTemplateValues(t *testing.T) {
+	var got []string
+	for _, v := range jsTokenize([]byte("`a${b}c\\n`")) {
+		got = append(got, v.val)
+	}
+	want := []string{"a", "b", "c\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %q, want %q", got, want)
+	}
+}
+
+func TestJSLinksAfterTemplates(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			"шаблон внутри функции",
+			"function f(){ var a = `x${1}y`; }\nimport('./late.js');",
+			[]string{"import() http://site.test/js/late.js"},
+		},
+		{
+			"вложенные шаблоны и скобки",
+			"if (a) { x = `${ `${ {b:1}.b }` }`; } else { y = {}; }\nfetch('/data.json');",
+			[]string{"fetch() http://site.test/data.json"},
+		},
+		{
+			"строка внутри подстановки",
+			"const u = `${base}/x`; import('./a.js');",
+			[]string{"import() http://site.test/js/a.js"},
+		},
+		{
+			"фигурная скобка в тексте шаблона",
+			"const s = `}{ ${a} }`; import('./b.js');",
+			[]string{"import() http://site.test/js/b.js"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsLinks(t, tt.src); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("links = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
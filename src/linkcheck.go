@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Проверить доступность ресурса без скачивания тела.
+//
+// Используется для внешних ссылок в режиме проверки ссылок.
+// Выполняет запрос HEAD, если сервер его не поддерживает - запрос
+// GET, тело ответа при этом не читается.
+func (s *Scanner) check(obj *Source) {
+	if err := s.acquire(); err != nil {
+		s.cancelSource(obj, err)
+		return
+	}
+	defer func() { <-s.limiter }()
+
+	obj.mu.Lock()
+	obj.dateStart = time.Now()
+	obj.mu.Unlock()
+
+	method := http.MethodHead
+	for {
+		if err := s.wait(); err != nil {
+			s.cancelSource(obj, err)
+			return
+		}
+
+		obj.mu.Lock()
+		obj.state = SourceRequest
+		obj.mu.Unlock()
+		s.emitState(obj)
+
+		req, err := http.NewRequestWithContext(s.ctx, method, obj.url.String(), nil)
+		if err != nil {
+			obj.mu.Lock()
+			obj.state = SourceRequestError
+			obj.err = err
+			obj.mu.Unlock()
+			s.emitState(obj)
+			s.logSource(slog.LevelError, obj, "Пропуск внешней ссылки: некорректный запрос", "err", err)
+			return
+		}
+		resp, err := s.fetch(req)
+
+		// Остановка сканера:
+		if err != nil && s.ctx.Err() != nil {
+			s.cancelSource(obj, s.ctx.Err())
+			return
+		}
+
+		// Сетевая ошибка:
+		if err != nil {
+			obj.mu.Lock()
+			obj.repeats++
+			obj.err = err
+			try := obj.repeats
+			if obj.repeats > s.params.RepeatsMax {
+				obj.state = SourceRequestError
+				obj.mu.Unlock()
+				s.emitState(obj)
+				s.logSource(slog.LevelWarn, obj, "Ошибка проверки внешней ссылки: исчерпан лимит попыток запроса", "err", err)
+				return
+			}
+			obj.state = SourceRequestWaitRepeat
+			obj.mu.Unlock()
+			s.emitState(obj)
+			if err := s.waitRepeat(try); err != nil {
+				s.cancelSource(obj, err)
+				return
+			}
+			continue
+		}
+		resp.Body.Close()
+		obj.mu.Lock()
+		obj.status = resp.StatusCode
+		obj.mu.Unlock()
+
+		// Сервер не поддерживает HEAD:
+		if method == http.MethodHead && (resp.StatusCode == 405 || resp.StatusCode == 501) {
+			method = http.MethodGet
+			continue
+		}
+
+		// Превышение кол-ва запросов:
+		if resp.StatusCode == 503 {
+			obj.mu.Lock()
+			obj.state = SourceRequestWaitRepeat
+			obj.err = fmt.Errorf("%v", resp.Status)
+			obj.repeats++
+			try := obj.repeats
+			obj.mu.Unlock()
+			s.emitState(obj)
+
+			if err := s.waitRepeat(try); err != nil {
+				s.cancelSource(obj, err)
+				return
+			}
+			continue
+		}
+
+		// Любые ошибки:
+		if resp.StatusCode >= 400 {
+			obj.mu.Lock()
+			obj.state = SourceRequestError
+			obj.err = fmt.Errorf("%v", resp.Status)
+			obj.mu.Unlock()
+			s.emitState(obj)
+			s.logSource(slog.LevelWarn, obj, "Битая внешняя ссылка")
+			return
+		}
+
+		obj.mu.Lock()
+		obj.state = SourceChecked
+		obj.err = nil
+		obj.mime = resp.Header.Get("Content-Type")
+		obj.mu.Unlock()
+		s.emitState(obj)
+		return
+	}
+}
+
+// Проверка статуса на битую ссылку.
+func isBroken(state SourceState) bool {
+	return state == SourceRequestError || state == SourceDownloadError
+}
+
+// Битая ссылка и все места, где она встречается.
+type BrokenLink struct {
+	URL    string          `json:"url"`
+	State  string          `json:"state"`
+	Status string          `json:"status"`
+	Err    string          `json:"error,omitempty"`
+	Refs   []BrokenLinkRef `json:"refs"`
+}
+
+// Место, в котором найдена битая ссылка.
+type BrokenLinkRef struct {
+	Page string `json:"page"`
+	Tag  string `json:"tag,omitempty"`
+	Attr string `json:"attr"`
+}
+
+// Страница, содержащая битые ссылки.
+type BrokenPage struct {
+	Page  string           `json:"page"`
+	Links []BrokenPageLink `json:"links"`
+}
+
+// Битая ссылка на странице.
+type BrokenPageLink struct {
+	URL  string `json:"url"`
+	Tag  string `json:"tag,omitempty"`
+	Attr string `json:"attr"`
+	Err  string `json:"error,omitempty"`
+}
+
+// Получить список битых ссылок, сгруппированных по адресу.
+// Список отсортирован по URL.
+func (s *Scanner) BrokenLinks() []BrokenLink {
+	s.mu.RLock()
+	src := s.sources
+	s.mu.RUnlock()
+	if src == nil {
+		return nil
+	}
+
+	res := make([]BrokenLink, 0)
+	for _, obj := range src.List() {
+		refs := obj.Refs()
+		obj.mu.RLock()
+		if !isBroken(obj.state) {
+			obj.mu.RUnlock()
+			continue
+		}
+
+		v := BrokenLink{
+			URL:    obj.url.String(),
+			State:  obj.state.Code(),
+			Status: obj.state.String(),
+			Refs:   make([]BrokenLinkRef, 0, len(refs)),
+		}
+		if obj.err != nil {
+			v.Err = obj.err.Error()
+		}
+		for _, ref := range refs {
+			v.Refs = append(v.Refs, BrokenLinkRef{
+				Page: ref.From.String(),
+				Tag:  ref.Tag,
+				Attr: ref.Attr,
+			})
+		}
+		obj.mu.RUnlock()
+
+		res = append(res, v)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].URL < res[j].URL
+	})
+
+	return res
+}
+
+// Получить список страниц, содержащих битые ссылки.
+// Список отсортирован по адресу страницы.
+func (s *Scanner) BrokenPages() []BrokenPage {
+	m := make(map[string]*BrokenPage)
+	for _, link := range s.BrokenLinks() {
+		for _, ref := range link.Refs {
+			p, ok := m[ref.Page]
+			if !ok {
+				p = &BrokenPage{Page: ref.Page}
+				m[ref.Page] = p
+			}
+			p.Links = append(p.Links, BrokenPageLink{
+				URL:  link.URL,
+				Tag:  ref.Tag,
+				Attr: ref.Attr,
+				Err:  link.Err,
+			})
+		}
+	}
+
+	res := make([]BrokenPage, 0, len(m))
+	for _, p := range m {
+		res = append(res, *p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Page < res[j].Page
+	})
+
+	return res
+}
+
+// Получить текстовый отчёт о битых ссылках.
+// Содержит две группировки: по битым ссылкам и по страницам.
+func (s *Scanner) LinksReport() string {
+	links := s.BrokenLinks()
+	pages := s.BrokenPages()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Битые ссылки: %v\n%v\n", len(links), line(50))
+	for _, link := range links {
+		fmt.Fprintf(&b, "%v\n    Ошибка: %v\n", link.URL, link.Err)
+		for _, ref := range link.Refs {
+			fmt.Fprintf(&b, "    %v\n", brokenRef(ref.Page, ref.Tag, ref.Attr))
+		}
+	}
+
+	fmt.Fprintf(&b, "\nСтраницы с битыми ссылками: %v\n%v\n", len(pages), line(50))
+	for _, page := range pages {
+		fmt.Fprintf(&b, "%v\n", page.Page)
+		for _, link := range page.Links {
+			fmt.Fprintf(&b, "    %v %v: %v\n", brokenRef("", link.Tag, link.Attr), link.URL, link.Err)
+		}
+	}
+
+	return b.String()
+}
+
+// Текстовое представление места битой ссылки.
+func brokenRef(page, tag, attr string) string {
+	if tag == "" {
+		return strings.TrimSpace(fmt.Sprintf("%v (%v)", page, attr))
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v <%v %v>", page, tag, attr))
+}
+
+// Сохранить отчёт о битых ссылках.
+// Путь указывается без расширения, создаются два файла: *.txt и *.json
+func (s *Scanner) ExportLinksFile(path string) error {
+	if err := os.WriteFile(path+".txt", []byte(s.LinksReport()), 0777); err != nil {
+		return fmt.Errorf("Не удалось записать отчёт о битых ссылках: \"%v\": %w", path+".txt", err)
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Links []BrokenLink `json:"links"`
+		Pages []BrokenPage `json:"pages"`
+	}{
+		Links: s.BrokenLinks(),
+		Pages: s.BrokenPages(),
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".json", data, 0777); err != nil {
+		return fmt.Errorf("Не удалось записать отчёт о битых ссылках: \"%v\": %w", path+".json", err)
+	}
+
+	return nil
+}
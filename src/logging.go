@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Создать журнал сканера.
+// Записи пишутся в w в текстовом формате "ключ=значение"
+// или в формате JSON, если указан флаг json.
+func newLogger(w io.Writer, level slog.Leveler, json bool) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if json {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Пустой журнал, отбрасывающий все записи.
+// Используется до запуска сканера.
+func nopLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// Записать в журнал событие обработки ресурса.
+// К записи добавляются поля ресурса: url, state, status и duration.
+//
+// Ресурс не должен быть заблокирован вызывающим кодом.
+func (s *Scanner) logSource(level slog.Level, obj *Source, msg string, args ...any) {
+	if !s.log.Enabled(context.Background(), level) {
+		return
+	}
+
+	obj.mu.RLock()
+	attrs := make([]any, 0, len(args)+4)
+	attrs = append(attrs,
+		slog.String("url", obj.url.String()),
+		slog.String("state", obj.state.Code()),
+	)
+	if obj.status != 0 {
+		attrs = append(attrs, slog.Int("status", obj.status))
+	}
+	if !obj.dateStart.IsZero() {
+		attrs = append(attrs, slog.Duration("duration", time.Since(obj.dateStart)))
+	}
+	obj.mu.RUnlock()
+
+	s.log.Log(context.Background(), level, msg, append(attrs, args...)...)
+}
@@ -2,12 +2,18 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
+
+	"golang.org/x/net/html/charset"
 )
 
 // Ввод команд пользователем
@@ -27,12 +33,84 @@ func init() {
 
 // Точка входа
 func main() {
+	// Команды: serve, ...
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
 	var params = ScannerParams{
 		URL:           "",
 		ReplaceOutDir: false,
 		RepeatsMax:    10,
+		Reports:       []ReportFormat{ReportJSON, ReportCSV, ReportHTML},
 	}
 
+	// Аргументы командной строки:
+	flag.StringVar(&params.OutputDir, "out", "", "Каталог для сохранения сайтов, журнала и отчётов (По умолчанию: текущий)")
+	flag.StringVar(&params.Layout, "layout", DEFAULT_LAYOUT, "Шаблон папки сайта: {host}, {hostname}, {port}, {scheme}, {date}, {time}")
+	flag.TextVar(&params.FileNames, "filenames", FileNamesUTF8, "Имена файлов с символами вне ASCII: utf8, escape")
+	flag.TextVar(&params.OutputFormat, "format", OutputDirectory, "Формат вывода файлов сайта: dir, zip, tar.gz, warc")
+	flag.TextVar(&params.Dedup, "dedup", DedupOff, "Дедупликация одинаковых файлов: off, hardlink, symlink, manifest")
+	flag.BoolVar(&params.WARC, "warc", false, "Дополнительно писать ответы сервера в WARC архив с индексом CDX")
+	flag.StringVar(&params.LogFile, "log", "", "Путь к файлу журнала (По умолчанию: <out>/<host>.log)")
+	flag.BoolVar(&params.LinkCheck, "check", false, "Режим проверки ссылок: сайт сканируется без сохранения файлов")
+	flag.BoolVar(&params.CheckExternal, "external", false, "Проверять доступность внешних ссылок запросом HEAD")
+	graph := flag.Bool("graph", false, "Сохранить граф ссылок сайта в форматах DOT, GraphML и JSON")
+	flag.BoolVar(&params.Render, "render", false, "Отрисовывать страницы в Chromium без окна: для одностраничных приложений")
+	flag.StringVar(&params.Chrome, "chrome", "", "Путь к браузеру для отрисовки страниц (По умолчанию: установленный Chromium, Chrome или Edge)")
+	flag.BoolVar(&params.NoSourceMaps, "no-sourcemaps", false, "Не загружать карты исходного кода: *.js.map, *.css.map")
+	flag.Func("charset", "Кодировка страниц без объявленной кодировки, например: windows-1251 (По умолчанию: "+DEFAULT_CHARSET+")", func(v string) error {
+		if e, _ := charset.Lookup(v); e == nil {
+			return fmt.Errorf("Неизвестная кодировка: \"%v\"", v)
+		}
+		params.Charset = v
+		return nil
+	})
+	flag.BoolVar(&params.Transcode, "utf8", false, "Сохранять текстовые файлы в UTF-8 с исправлением объявления кодировки")
+	jsPaths := flag.Bool("js-paths", true, "Искать в JavaScript строки, похожие на пути к файлам: \"/api/data.json\"")
+	flag.TextVar(&params.LogLevel, "log-level", slog.LevelInfo, "Уровень журнала: debug, info, warn, error")
+	flag.BoolVar(&params.LogJSON, "log-json", false, "Писать журнал в формате JSON")
+	flag.Parse()
+	if *graph {
+		params.Graphs = []GraphFormat{GraphDOT, GraphML, GraphJSON}
+	}
+	if !*jsPaths {
+		js := DefaultJSParams()
+		js.Paths = false
+		params.JS = &js
+	}
+
+	// Остановка сканирования по Ctrl+C или сигналу завершения.
+	// Отмена контекста останавливает сканер, повторный сигнал завершает
+	// программу немедленно. Контекст создаётся для каждого запуска
+	// сканера: отменённый однажды, он остановил бы и новое сканирование.
+	stop := func() {}
+	defer func() { stop() }()
+	notify := func() context.Context {
+		stop()
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		stop = cancel
+		go func() {
+			<-ctx.Done()
+			cancel()
+		}()
+		return ctx
+	}
+
+	// Оповещение об изменении состояния сканера:
+	states := make(chan ScannerState, 1)
+	scanner.Subscribe(ObserverFunc(func(e Event) {
+		if e.Type != EventScannerState {
+			return
+		}
+		select {
+		case states <- e.State:
+		default:
+		}
+	}))
+
 START:
 
 	// Запуск:
@@ -40,10 +118,10 @@ START:
 	fmt.Println("Добро пожаловать в программу " + APP_NAME + " v:" + VERSION)
 
 	// Запрос URL:
-	params.URL = inputURL("Введите URL сайта для копирования:")
+	params.URL = inputURL("Введите URL сайта для копирования или путь к папке сайта на диске:")
 
 	// Запуск:
-	err := scanner.Start(params)
+	err := scanner.Start(notify(), params)
 	if err != nil {
 		fmt.Println(err)
 		if inputYes("Хотите указать другой URL? (y/n)") {
@@ -56,10 +134,10 @@ START:
 	// Ожидание результата:
 	for {
 		switch scanner.State() {
-		case ScannerScanning, ScannerPreparing:
+		case ScannerScanning, ScannerPreparing, ScannerPaused:
 		case ScannerReady:
 			goto START
-		case ScannerComplete:
+		case ScannerComplete, ScannerStopped:
 			goto FINISH
 		case ScannerIncorrectURL:
 			cls()
@@ -73,14 +151,18 @@ START:
 			}
 		case ScannerOutputDirExist:
 			cls()
-			if inputYes("Папка с данными для этого сайта уже существует: \"" + scanner.Dir() + "\"\nУдалить старое содержимое? (y/n)") {
+			if inputYes("Данные для этого сайта уже существуют: \"" + scanner.Dir() + "\"\nУдалить старое содержимое? (y/n)") {
 				params.ReplaceOutDir = true
-				scanner.Start(params)
+				scanner.Start(notify(), params)
 			} else {
 				fmt.Println("Операция отменена")
 				time.Sleep(time.Second)
 				goto START
 			}
+		case ScannerRendererError:
+			cls()
+			fmt.Println(scanner.Err().Error())
+			goto EXIT
 		case ScannerOutputDirError:
 			cls()
 			fmt.Println(scanner.Err().Error())
@@ -96,20 +178,31 @@ START:
 			panic("Я не знаю такого состояния сканера")
 		}
 
-		// Вывод информации и ожидание:
+		// Вывод информации и ожидание смены состояния сканера:
 		cls()
 		fmt.Println(scanner.Report(false))
-		time.Sleep(time.Millisecond * 500)
+		select {
+		case <-states:
+		case <-time.After(time.Millisecond * 500):
+		}
 	}
 
 FINISH:
 
 	// Завершено:
-	log.Println("\n\nОтчёт сканирования:\n" + scanner.Report(true))
-
 	cls()
 	fmt.Println(scanner.Report(true))
-	fmt.Println("Сайт скопирован")
+	if params.LinkCheck {
+		fmt.Println(scanner.LinksReport())
+	}
+	switch {
+	case scanner.State() == ScannerStopped:
+		fmt.Println("Сканирование остановлено")
+	case params.LinkCheck:
+		fmt.Println("Проверка ссылок завершена")
+	default:
+		fmt.Println("Сайт скопирован")
+	}
 	fmt.Println("Нажмите ввод для выхода из программы..")
 	reader.ReadRune()
 
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Расширение файла манифеста.
+// Манифест пишется рядом с данными сайта: <host>.manifest.jsonl
+const MANIFEST_EXT = ".manifest.jsonl"
+
+// Запись манифеста: один загруженный ресурс.
+//
+// Манифест - файл JSON Lines, по одной записи в строке. Записи
+// добавляются по мере обработки ресурсов, поэтому манифест прерванного
+// сканирования тоже можно прочитать. См.: LoadManifest()
+type ManifestEntry struct {
+	URL         string      `json:"url"`
+	FinalURL    string      `json:"final_url,omitempty"`
+	Path        string      `json:"path,omitempty"`
+	File        string      `json:"file,omitempty"`
+	Renamed     bool        `json:"renamed,omitempty"`
+	Duplicate   string      `json:"duplicate_of,omitempty"`
+	External    bool        `json:"external,omitempty"`
+	State       string      `json:"state"`
+	Status      int         `json:"status"`
+	ContentType string      `json:"content_type,omitempty"`
+	Headers     http.Header `json:"headers,omitempty"`
+	Size        int64       `json:"size"`
+	Hash        string      `json:"hash,omitempty"`
+	FetchTime   time.Time   `json:"fetch_time"`
+	Referrer    string      `json:"referrer,omitempty"`
+}
+
+// Манифест, загруженный из файла.
+type Manifest struct {
+	Entries []ManifestEntry // Записи в порядке следования в файле
+	m       map[string]int  // Индекс последней записи по URL
+}
+
+// Найти запись манифеста по URL ресурса.
+// Если ресурс записан несколько раз (Повторное сканирование),
+// возвращает последнюю запись. Возвращает nil, если записи нет.
+func (m *Manifest) Lookup(url string) *ManifestEntry {
+	i, ok := m.m[url]
+	if !ok {
+		return nil
+	}
+	return &m.Entries[i]
+}
+
+// Загрузить манифест из файла.
+//
+// Последняя строка может быть оборвана, если сканирование было
+// прервано во время записи, - такая строка пропускается.
+func LoadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть манифест: \"%v\": %w", path, err)
+	}
+	defer f.Close()
+
+	m := &Manifest{m: make(map[string]int)}
+	r := bufio.NewScanner(f)
+	r.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var broken error
+	for n := 1; r.Scan(); n++ {
+		line := strings.TrimSpace(r.Text())
+		if line == "" {
+			continue
+		}
+		if broken != nil {
+			return nil, broken
+		}
+
+		var v ManifestEntry
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			broken = fmt.Errorf("Ошибка чтения манифеста: \"%v\", строка %v: %w", path, n, err)
+			continue
+		}
+		m.Entries = append(m.Entries, v)
+		m.m[v.URL] = len(m.Entries) - 1
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("Ошибка чтения манифеста: \"%v\": %w", path, err)
+	}
+
+	return m, nil
+}
+
+// Писатель манифеста
+type manifestWriter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Создать файл манифеста.
+func newManifestWriter(path string) (*manifestWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось создать манифест: \"%v\": %w", path, err)
+	}
+	return &manifestWriter{file: f}, nil
+}
+
+// Дописать запись в манифест.
+func (w *manifestWriter) Write(v ManifestEntry) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.file.Write(append(data, '\n'))
+	return err
+}
+
+// Закрыть файл манифеста.
+func (w *manifestWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// Получить запись манифеста для ресурса.
+func (s *Source) ManifestEntry() ManifestEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v := ManifestEntry{
+		URL:         s.url.String(),
+		Path:        s.path,
+		File:        s.file,
+		Renamed:     s.renamed,
+		External:    s.isExternal,
+		State:       s.state.Code(),
+		Status:      s.status,
+		ContentType: s.header.Get("Content-Type"),
+		Headers:     s.header,
+		Size:        s.size,
+		Hash:        s.hash,
+		FetchTime:   s.dateStart,
+	}
+	if s.finalURL != nil {
+		v.FinalURL = s.finalURL.String()
+	}
+	if s.dupOf != nil {
+		v.Duplicate = s.dupOf.url.String()
+	}
+	if s.referrer != nil {
+		v.Referrer = s.referrer.String()
+	}
+
+	return v
+}
+
+// Получить путь к файлу манифеста.
+// Манифест пишется рядом с папкой или архивом сайта.
+// п.с. Сканер с Lock()
+func (s *Scanner) manifestPath() string {
+	return strings.TrimSuffix(s.dir, s.params.OutputFormat.Ext()) + MANIFEST_EXT
+}
+
+// Записать ресурс в манифест, если ресурс был запрошен.
+func (s *Scanner) record(obj *Source) {
+	if s.manifest == nil || obj.Status() == 0 {
+		return
+	}
+
+	if err := s.manifest.Write(obj.ManifestEntry()); err != nil {
+		s.logSource(slog.LevelError, obj, "Не удалось записать ресурс в манифест", "err", err)
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Формат вывода файлов сайта.
+type OutputFormat int
+
+// Получить текстовое представление формата вывода.
+func (v OutputFormat) String() string {
+	switch v {
+	case OutputDirectory:
+		return "Папка"
+	case OutputZIP:
+		return "ZIP"
+	case OutputTarGz:
+		return "TAR.GZ"
+	case OutputWARC:
+		return "WARC"
+	default:
+		return "Unknown"
+	}
+}
+
+// Получить расширение файла архива для формата вывода.
+// Для папки возвращает пустую строку.
+func (v OutputFormat) Ext() string {
+	switch v {
+	case OutputZIP:
+		return ".zip"
+	case OutputTarGz:
+		return ".tar.gz"
+	case OutputWARC:
+		return WARC_EXT
+	default:
+		return ""
+	}
+}
+
+// Получить код формата вывода: dir, zip, tar.gz, warc
+func (v OutputFormat) MarshalText() ([]byte, error) {
+	switch v {
+	case OutputDirectory:
+		return []byte("dir"), nil
+	case OutputZIP:
+		return []byte("zip"), nil
+	case OutputTarGz:
+		return []byte("tar.gz"), nil
+	case OutputWARC:
+		return []byte("warc"), nil
+	default:
+		return nil, fmt.Errorf("Неизвестный формат вывода: %d", int(v))
+	}
+}
+
+// Разобрать код формата вывода: dir, zip, tar.gz, warc
+func (v *OutputFormat) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "dir", "":
+		*v = OutputDirectory
+	case "zip":
+		*v = OutputZIP
+	case "tar.gz", "tgz":
+		*v = OutputTarGz
+	case "warc":
+		*v = OutputWARC
+	default:
+		return fmt.Errorf("Неизвестный формат вывода: \"%s\"", text)
+	}
+	return nil
+}
+
+const (
+
+	// Файлы сайта пишутся в папку
+	OutputDirectory OutputFormat = iota
+
+	// Файлы сайта пишутся в ZIP архив
+	OutputZIP
+
+	// Файлы сайта пишутся в архив TAR, сжатый GZIP
+	OutputTarGz
+
+	// Файлы сайта не сохраняются, ответы сервера пишутся
+	// только в WARC архив. См.: ScannerParams.WARC
+	OutputWARC
+)
+
+// Хранилище для сохранения файлов сайта.
+//
+// Методы хранилища вызываются параллельно из рабочих горутин
+// сканера по мере обработки ресурсов.
+type Output interface {
+
+	// Записать файл целиком.
+	// Путь name - относительный путь внутри сайта с разделителем "/".
+	WriteFile(name string, data []byte) error
+
+	// Получить расположение файла для отчётов и журнала.
+	Path(name string) string
+
+	// Завершить запись и освободить ресурсы.
+	Close() error
+}
+
+// Подготовить хранилище для сохранения файлов сайта.
+// Возвращает состояние сканера для случая ошибки.
+// п.с. Сканер с Lock()
+func (s *Scanner) openOutput() (ScannerState, error) {
+	base := s.dir
+
+	switch s.params.OutputFormat {
+	case OutputDirectory:
+		if state, err := s.openDir(); err != nil {
+			return state, err
+		}
+	case OutputZIP, OutputTarGz:
+		s.dir += s.params.OutputFormat.Ext()
+		f, state, err := s.createFile(s.dir)
+		if err != nil {
+			return state, err
+		}
+		if s.params.OutputFormat == OutputZIP {
+			s.out = newZipOutput(f)
+		} else {
+			s.out = newTarGzOutput(f)
+		}
+	case OutputWARC:
+		s.dir += s.params.OutputFormat.Ext()
+	}
+
+	// WARC архив: (Рядом с файлами сайта или вместо них)
+	if s.params.WARC || s.params.OutputFormat == OutputWARC {
+		f, state, err := s.createFile(base + WARC_EXT)
+		if err == nil {
+			s.warc, err = newWARCWriter(f)
+			if err != nil {
+				f.Close()
+				err = fmt.Errorf("Не удалось создать WARC архив: \"%v\": %w", f.Name(), err)
+				state = ScannerOutputDirError
+			}
+		}
+		if err != nil {
+			if s.out != nil {
+				s.out.Close()
+				s.out = nil
+			}
+			return state, err
+		}
+	}
+
+	// Манифест загруженных ресурсов:
+	m, err := newManifestWriter(s.manifestPath())
+	if err != nil {
+		if s.out != nil {
+			s.out.Close()
+			s.out = nil
+		}
+		if s.warc != nil {
+			s.warc.Close()
+			s.warc = nil
+		}
+		return ScannerOutputDirError, err
+	}
+	s.manifest = m
+
+	return s.state, nil
+}
+
+// Завершить запись файлов сайта, WARC архива и манифеста.
+func (s *Scanner) closeOutput() {
+	if s.out != nil {
+		if err := s.out.Close(); err != nil {
+			s.log.Error("Не удалось завершить запись файлов сайта", "dir", s.dir, "err", err)
+		}
+	}
+	if s.warc != nil {
+		if err := s.warc.Close(); err != nil {
+			s.log.Error("Не удалось завершить запись WARC архива", "err", err)
+		}
+	}
+	if s.manifest != nil {
+		if err := s.manifest.Close(); err != nil {
+			s.log.Error("Не удалось завершить запись манифеста", "err", err)
+		}
+	}
+}
+
+// Создать файл для записи данных сайта: архив, WARC, ...
+// Существующий файл заменяется только с флагом: ScannerParams.ReplaceOutDir
+// п.с. Сканер с Lock()
+func (s *Scanner) createFile(name string) (*os.File, ScannerState, error) {
+	file, err := os.Stat(name)
+	if err == nil {
+		if file.IsDir() {
+			return nil, ScannerOutputDirError, fmt.Errorf("Ошибка, путь для создания архива с данными сайта занят папкой: \"%v\"", name)
+		}
+		if !s.params.ReplaceOutDir {
+			return nil, ScannerOutputDirExist, fmt.Errorf("Архив с данными сайта уже существует, сперва удалите его: \"%v\"", name)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, ScannerOutputDirError, fmt.Errorf("Ошибка доступа к архиву для сохранения: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return nil, ScannerOutputDirError, fmt.Errorf("Не удалось создать папку для архива с данными сайта: %w", err)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, ScannerOutputDirError, fmt.Errorf("Не удалось создать архив для данных сайта: \"%v\": %w", name, err)
+	}
+
+	return f, s.state, nil
+}
+
+// Подготовить папку для сохранения файлов сайта.
+// п.с. Сканер с Lock()
+func (s *Scanner) openDir() (ScannerState, error) {
+	file, err := os.Stat(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			// Папка есть а доступа к ней нет:
+			return ScannerOutputDirError, fmt.Errorf("Ошибка доступа к папке для сохранения: %w", err)
+		}
+
+		// Создаём новую папку:
+		if err := os.MkdirAll(s.dir, 0777); err != nil {
+			return ScannerOutputDirError, fmt.Errorf("Не удалось создать папку для данных сайта: %w", err)
+		}
+		s.out = &dirOutput{dir: s.dir}
+		return s.state, nil
+	}
+
+	// Тут лежит какойто файл:
+	if !file.IsDir() {
+		return ScannerOutputDirError, fmt.Errorf("Ошибка, путь для создания папки с данными сайта занят файлом: \"%v\"", s.dir)
+	}
+
+	// Папка уже существует:
+	if !s.params.ReplaceOutDir {
+		return ScannerOutputDirExist, fmt.Errorf("Папка для данных сайта уже существует, сперва удалите её: \"%v\"", s.dir)
+	}
+	if err := os.RemoveAll(s.dir); err != nil {
+		return ScannerOutputDirError, fmt.Errorf("Не удалось удалить старую папку с данными сайта: \"%v\": %w", s.dir, err)
+	}
+
+	// Создаём новую:
+	if err := os.MkdirAll(s.dir, 0777); err != nil {
+		return ScannerOutputDirError, fmt.Errorf("Не удалось создать новую папку для данных сайта: \"%v\": %w", s.dir, err)
+	}
+	s.out = &dirOutput{dir: s.dir}
+	return s.state, nil
+}
+
+// Хранилище файлов в папке
+type dirOutput struct {
+	dir string // Корневая папка сайта
+}
+
+// Записать файл в папку, создав недостающие подпапки.
+func (o *dirOutput) WriteFile(name string, data []byte) error {
+	p := o.Path(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data, 0777)
+}
+
+// Получить путь к файлу на диске.
+func (o *dirOutput) Path(name string) string {
+	return filepath.Join(o.dir, filepath.FromSlash(name))
+}
+
+// Создать ссылку на ранее записанный файл.
+// Символическая ссылка указывает относительный путь, чтобы папка
+// сайта оставалась рабочей после перемещения.
+func (o *dirOutput) Link(name string, target string, symbolic bool) error {
+	p := o.Path(name)
+	t := o.Path(target)
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !symbolic {
+		return os.Link(t, p)
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(p), t)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(rel, p)
+}
+
+// Папка не требует завершения записи.
+func (o *dirOutput) Close() error {
+	return nil
+}
+
+// Общая часть архивных хранилищ
+type archiveOutput struct {
+	mu    sync.Mutex
+	file  *os.File
+	names map[string]bool // Уже записанные файлы
+}
+
+// Получить имя записи в архиве.
+// Возвращает false, если такая запись уже есть в архиве.
+// п.с. Хранилище с Lock()
+func (o *archiveOutput) entry(name string) (string, bool) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if o.names[name] {
+		return name, false
+	}
+	o.names[name] = true
+	return name, true
+}
+
+// Получить расположение записи в архиве: "site.zip!/path/file.html"
+func (o *archiveOutput) Path(name string) string {
+	return o.file.Name() + "!/" + strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// Хранилище файлов в ZIP архиве
+type zipOutput struct {
+	archiveOutput
+	w *zip.Writer
+}
+
+// Создать хранилище в ZIP архиве.
+func newZipOutput(f *os.File) *zipOutput {
+	return &zipOutput{
+		archiveOutput: archiveOutput{file: f, names: make(map[string]bool)},
+		w:             zip.NewWriter(f),
+	}
+}
+
+// Записать файл в архив.
+// Повторная запись файла с тем же именем игнорируется.
+func (o *zipOutput) WriteFile(name string, data []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	name, ok := o.entry(name)
+	if !ok {
+		return nil
+	}
+
+	w, err := o.w.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return o.w.Flush()
+}
+
+// Дописать оглавление архива и закрыть файл.
+func (o *zipOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	err := o.w.Close()
+	if err2 := o.file.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Хранилище файлов в архиве TAR.GZ
+type tarGzOutput struct {
+	archiveOutput
+	z *gzip.Writer
+	w *tar.Writer
+}
+
+// Создать хранилище в архиве TAR.GZ
+func newTarGzOutput(f *os.File) *tarGzOutput {
+	z := gzip.NewWriter(f)
+	return &tarGzOutput{
+		archiveOutput: archiveOutput{file: f, names: make(map[string]bool)},
+		z:             z,
+		w:             tar.NewWriter(z),
+	}
+}
+
+// Записать файл в архив.
+// Повторная запись файла с тем же именем игнорируется.
+func (o *tarGzOutput) WriteFile(name string, data []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	name, ok := o.entry(name)
+	if !ok {
+		return nil
+	}
+
+	err := o.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := o.w.Write(data); err != nil {
+		return err
+	}
+
+	return o.w.Flush()
+}
+
+// Создать в архиве ссылку на ранее записанный файл.
+// Повторная запись файла с тем же именем игнорируется.
+func (o *tarGzOutput) Link(name string, target string, symbolic bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	name, ok := o.entry(name)
+	if !ok {
+		return nil
+	}
+	target = strings.TrimPrefix(path.Clean("/"+target), "/")
+
+	h := &tar.Header{
+		Typeflag: tar.TypeLink,
+		Name:     name,
+		Linkname: target,
+		Mode:     0644,
+		ModTime:  time.Now(),
+		Format:   tar.FormatPAX,
+	}
+	if symbolic {
+		rel, err := filepath.Rel(path.Dir(name), target)
+		if err != nil {
+			return err
+		}
+		h.Typeflag = tar.TypeSymlink
+		h.Linkname = filepath.ToSlash(rel)
+		h.Mode = 0777
+	}
+	if err := o.w.WriteHeader(h); err != nil {
+		return err
+	}
+
+	return o.w.Flush()
+}
+
+// Дописать окончание архива и закрыть файл.
+func (o *tarGzOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	err := o.w.Close()
+	if err2 := o.z.Close(); err == nil {
+		err = err2
+	}
+	if err2 := o.file.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
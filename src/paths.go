@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Шаблон расположения папки сайта по умолчанию.
+// См.: ScannerParams.Layout
+const DEFAULT_LAYOUT = "{host}"
+
+// Получить базовый каталог для вывода данных.
+// Если каталог не задан, используется текущий рабочий каталог.
+func (s *Scanner) outputDir() (string, error) {
+	dir := s.params.OutputDir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("Не удалось получить текущий рабочий каталог: %w", err)
+		}
+		dir = wd
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("Не удалось получить абсолютный путь каталога для вывода: \"%v\": %w", s.params.OutputDir, err)
+	}
+
+	return dir, nil
+}
+
+// Подставить значения в шаблон расположения папки сайта.
+//
+// Поддерживаемые подстановки:
+//   - {host} - хост с портом: "site.ru", "site.ru:8080";
+//   - {hostname} - хост без порта: "site.ru";
+//     международные доменные имена - по ScannerParams.FileNames;
+//   - {port} - порт, если указан в URL;
+//   - {scheme} - протокол: "http", "https";
+//   - {date} - дата запуска: "2006-01-02";
+//   - {time} - время запуска: "15-04-05".
+//
+// Результат - относительный путь, который не может выходить за
+// пределы базового каталога.
+func (s *Scanner) expandLayout(layout string) (string, error) {
+	if layout == "" {
+		layout = DEFAULT_LAYOUT
+	}
+
+	r := strings.NewReplacer(
+		"{host}", s.hostName(s.url.Host),
+		"{hostname}", s.hostName(s.url.Hostname()),
+		"{port}", s.url.Port(),
+		"{scheme}", s.url.Scheme,
+		"{date}", s.dateStart.Format("2006-01-02"),
+		"{time}", s.dateStart.Format("15-04-05"),
+	)
+	p := filepath.Clean(filepath.FromSlash(r.Replace(layout)))
+
+	if filepath.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("Шаблон расположения папки сайта должен задавать вложенный путь: \"%v\"", layout)
+	}
+
+	return p, nil
+}
+
+// Получить хост для имён папки сайта и файла журнала.
+// Международные доменные имена пишутся в Unicode, если это
+// разрешает политика имён файлов: "пример.рф", иначе в punycode.
+// Для сайта на диске используется имя его папки.
+func (s *Scanner) hostName(host string) string {
+	// Сайт на диске: имя его папки
+	if s.url.Scheme == "file" {
+		if name := path.Base(s.rootPath()); name != "/" && name != "." {
+			return name
+		}
+		return "file"
+	}
+	if s.params.FileNames == FileNamesUTF8 {
+		return unicodeHost(host)
+	}
+	return host
+}
+
+// Получить путь к файлу журнала.
+// Если путь не задан, журнал пишется в базовый каталог: <host>.log
+func (s *Scanner) logPath() (string, error) {
+	if s.params.LogFile == "" {
+		return filepath.Join(s.home, s.hostName(s.url.Host)+".log"), nil
+	}
+
+	p, err := filepath.Abs(s.params.LogFile)
+	if err != nil {
+		return "", fmt.Errorf("Не удалось получить абсолютный путь файла журнала: \"%v\": %w", s.params.LogFile, err)
+	}
+
+	return p, nil
+}
+
+// Получить путь для файлов отчётов без расширения.
+// Отчёты пишутся рядом с журналом и называются так же, как он:
+// <host>.log -> <host>.report.json, <host>.graph.dot, ...
+func (s *Scanner) reportPath(suffix string) string {
+	return strings.TrimSuffix(s.logFile, filepath.Ext(s.logFile)) + "." + suffix
+}
+
+// Политика имён файлов для сегментов пути с символами вне ASCII.
+type FileNamePolicy int
+
+// Получить текстовое представление политики имён файлов.
+func (v FileNamePolicy) String() string {
+	switch v {
+	case FileNamesUTF8:
+		return "UTF-8"
+	case FileNamesEscape:
+		return "Процентное кодирование"
+	default:
+		return "Unknown"
+	}
+}
+
+// Получить код политики имён файлов: utf8, escape
+func (v FileNamePolicy) MarshalText() ([]byte, error) {
+	switch v {
+	case FileNamesUTF8:
+		return []byte("utf8"), nil
+	case FileNamesEscape:
+		return []byte("escape"), nil
+	default:
+		return nil, fmt.Errorf("Неизвестная политика имён файлов: %d", int(v))
+	}
+}
+
+// Разобрать код политики имён файлов: utf8, escape
+func (v *FileNamePolicy) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "utf8", "utf-8", "":
+		*v = FileNamesUTF8
+	case "escape":
+		*v = FileNamesEscape
+	default:
+		return fmt.Errorf("Неизвестная политика имён файлов: \"%s\"", text)
+	}
+	return nil
+}
+
+const (
+
+	// Имена файлов в UTF-8, как в адресе: "каталог/файл.html".
+	// Имена приводятся к нормальной форме NFC, чтобы файл находился
+	// по одному имени в Windows, Linux и macOS.
+	FileNamesUTF8 FileNamePolicy = iota
+
+	// Символы вне ASCII записываются процентным кодированием, как в
+	// адресе: "%D0%BA%D0%B0%D1%82%D0%B0%D0%BB%D0%BE%D0%B3/%D1%84%D0%B0%D0%B9%D0%BB.html".
+	// Подходит для файловых систем и архивов без поддержки Unicode.
+	FileNamesEscape
+)
+
+// Максимальная длина имени файла в байтах.
+// Ограничение большинства файловых систем: ext4, NTFS, APFS.
+const FILE_NAME_MAX = 255
+
+// Имена устройств Windows, которые нельзя использовать как имена файлов
+// с любым расширением: "con.html", "nul.txt", ...
+var reservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// Получить путь для сохранения файла из пути URL с разделителем "/".
+// Каждый сегмент пути приводится к имени, допустимому во всех целевых
+// файловых системах. См.: fileName()
+func fileSegments(p string, policy FileNamePolicy) string {
+	a := strings.Split(p, "/")
+	for i, v := range a {
+		if v != "" {
+			a[i] = fileName(v, policy)
+		}
+	}
+	return strings.Join(a, "/")
+}
+
+// Получить имя файла или папки, допустимое во всех целевых файловых
+// системах, из сегмента пути URL:
+//   - имя приводится к нормальной форме NFC, символы вне ASCII
+//     записываются по политике имён файлов, байты некорректного
+//     UTF-8 всегда кодируются: "%EF%F0";
+//   - если в имени есть закодированные байты, сам символ "%" тоже
+//     кодируется: "%25", иначе файл "%D0%B4.html" и закодированное
+//     имя "д.html" совпадут;
+//   - недопустимые в Windows символы и управляющие символы заменяются
+//     на "_", как в queryName();
+//   - к именам устройств Windows и именам с точкой или пробелом в конце
+//     добавляется "_": "con.html" -> "con_.html";
+//   - слишком длинные имена обрезаются с добавлением хеша полного имени.
+func fileName(name string, policy FileNamePolicy) string {
+	name = norm.NFC.String(name)
+	escape := policy == FileNamesEscape || !utf8.ValidString(name)
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, n := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && n == 1:
+			fmt.Fprintf(&b, "%%%02X", name[i])
+		case r == '%' && escape:
+			b.WriteString("%25")
+		case r >= utf8.RuneSelf && policy == FileNamesEscape:
+			for _, c := range []byte(name[i : i+n]) {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		case r < 32 || r == 127 || strings.ContainsRune(`<>:"/\|?*`, r):
+			b.WriteByte('_')
+		default:
+			b.WriteString(name[i : i+n])
+		}
+		i += n
+	}
+	name = b.String()
+
+	// Имена устройств и точки в конце:
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if reservedNames[strings.ToLower(base)] {
+		name = base + "_" + ext
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		name += "_"
+	}
+
+	// Длина имени:
+	if len(name) > FILE_NAME_MAX {
+		hash := fmt.Sprintf("~%x", sha256.Sum256([]byte(name)))[:9]
+		if len(ext) > 16 {
+			ext = ""
+		}
+		max := FILE_NAME_MAX - len(hash) - len(ext)
+		for max > 0 && !utf8.RuneStart(name[max]) {
+			max--
+		}
+		name = name[:max] + hash + ext
+	}
+
+	return name
+}
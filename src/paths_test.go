@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy FileNamePolicy
+		want   string
+	}{
+		{"index.html", FileNamesUTF8, "index.html"},
+		{"д.html", FileNamesUTF8, "д.html"},
+		{"д.html", FileNamesEscape, "%D0%B4.html"},
+		{"%D0%B4.html", FileNamesUTF8, "%D0%B4.html"},
+		{"%D0%B4.html", FileNamesEscape, "%25D0%25B4.html"},
+		{"100%.html", FileNamesEscape, "100%25.html"},
+		{"100%\xff.html", FileNamesUTF8, "100%25%FF.html"},
+		{"\xefд.html", FileNamesUTF8, "%EFд.html"},
+		{"a:b?.html", FileNamesUTF8, "a_b_.html"},
+		{"con.html", FileNamesUTF8, "con_.html"},
+		{"name.", FileNamesUTF8, "name._"},
+		{"e\u0301.html", FileNamesUTF8, "\u00e9.html"}, // NFD -> NFC
+	}
+	for _, tt := range tests {
+		if got := fileName(tt.name, tt.policy); got != tt.want {
+			t.Errorf("fileName(%q, %v) = %q, want %q", tt.name, tt.policy, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("я", 200) + ".html"
+	if got := fileName(long, FileNamesUTF8); len(got) > FILE_NAME_MAX || !strings.HasSuffix(got, ".html") {
+		t.Errorf("длинное имя: %v байт %q", len(got), got)
+	}
+}
+
+func TestFileNameEscapeIsUnique(t *testing.T) {
+	names := []string{"д.html", "%D0%B4.html", "%25D0%25B4.html", "%.html", "%25.html"}
+	for _, policy := range []FileNamePolicy{FileNamesUTF8, FileNamesEscape} {
+		seen := make(map[string]string)
+		for _, v := range names {
+			f := fileName(v, policy)
+			if prev, ok := seen[f]; ok {
+				t.Errorf("%v: %q и %q -> %q", policy, prev, v, f)
+			}
+			seen[f] = v
+		}
+	}
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Максимальное время отрисовки одной страницы.
+// По его истечении сохраняется DOM в текущем состоянии.
+const RENDER_TIMEOUT = time.Second * 30
+
+// Время без сетевых запросов, после которого страница
+// считается отрисованной.
+const RENDER_IDLE = time.Millisecond * 500
+
+// Максимальное кол-во страниц, отрисовываемых одновременно.
+const RENDER_TABS_MAX = 4
+
+// Результат отрисовки страницы.
+type Rendered struct {
+	HTML     []byte     // DOM страницы после выполнения скриптов
+	Requests []*url.URL // Адреса всех GET запросов, выполненных страницей
+}
+
+// Отрисовщик страниц.
+//
+// Загружает страницу в браузере, выполняет её скрипты и возвращает
+// итоговый DOM. Нужен для одностраничных приложений, ответ сервера
+// которых - пустой шаблон. По умолчанию сканер страницы не отрисовывает,
+// см.: ScannerParams.Render, ScannerParams.Renderer
+type Renderer interface {
+
+	// Отрисовать страницу.
+	// Вызывается из нескольких потоков одновременно.
+	Render(ctx context.Context, u *url.URL) (*Rendered, error)
+
+	// Освободить ресурсы отрисовщика.
+	Close() error
+}
+
+// Отрисовать HTML страницу, если сканер работает с отрисовщиком.
+// Все запросы страницы запускаются на сканирование. Возвращает
+// отрисованный DOM в UTF-8 или исходное тело и false, если отрисовать
+// не удалось. Объявление кодировки в DOM заменяется на UTF-8.
+func (s *Scanner) render(obj *Source, body []byte) ([]byte, bool) {
+	res, err := s.renderer.Render(s.ctx, obj.url)
+	if err != nil {
+		s.logSource(slog.LevelWarn, obj, "Не удалось отрисовать страницу, сохраняется ответ сервера", "err", err)
+		return body, false
+	}
+
+	res.HTML = fixMetaCharset(res.HTML)
+	obj.mu.Lock()
+	obj.charset = "utf-8"
+	setHeaderCharset(obj.header, "utf-8")
+	obj.size = int64(len(res.HTML))
+	obj.hash = fmt.Sprintf("%x", sha256.Sum256(res.HTML))
+	obj.mu.Unlock()
+	s.logSource(slog.LevelDebug, obj, "Страница отрисована", "requests", len(res.Requests))
+
+	for _, u := range res.Requests {
+		if u.String() != obj.url.String() {
+			s.follow(Edge{From: obj, Attr: "render"}, u)
+		}
+	}
+
+	return res.HTML, true
+}
+
+// Отрисовщик страниц в Chromium без окна.
+// Браузер управляется по протоколу Chrome DevTools, каждая
+// страница открывается в отдельной вкладке.
+type ChromeRenderer struct {
+	cmd  *exec.Cmd     // Процесс браузера
+	dir  string        // Временный профиль браузера
+	conn *cdpConn      // Соединение с браузером
+	tabs chan struct{} // Ограничитель кол-ва вкладок
+}
+
+// Запустить Chromium для отрисовки страниц.
+// Если путь к браузеру не указан, он ищется среди
+// установленных: Chromium, Google Chrome, Microsoft Edge.
+func NewChromeRenderer(path string) (*ChromeRenderer, error) {
+	if path == "" {
+		path = findChrome()
+		if path == "" {
+			return nil, errors.New("Не найден браузер для отрисовки страниц: установите Chromium или укажите путь к нему")
+		}
+	}
+
+	// Порт для управления выбирается заранее: подключения к браузеру
+	// разрешаются только с origin этого адреса, а не с любых страниц.
+	port, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось выбрать порт для управления браузером: %w", err)
+	}
+	origin := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+	dir, err := os.MkdirTemp("", "gomirror-chrome-")
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось создать профиль браузера: %w", err)
+	}
+	args := []string{
+		"--headless=new",
+		"--disable-gpu",
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--disable-extensions",
+		"--mute-audio",
+		"--remote-debugging-address=127.0.0.1",
+		"--remote-debugging-port=" + fmt.Sprint(port),
+		"--remote-allow-origins=" + origin,
+		"--user-data-dir=" + dir,
+	}
+	if os.Geteuid() == 0 {
+		// Chromium не запускается от root с песочницей:
+		args = append(args, "--no-sandbox")
+	}
+	cmd := exec.Command(path, append(args, "about:blank")...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("Не удалось запустить браузер: \"%v\": %w", path, err)
+	}
+	r := &ChromeRenderer{cmd: cmd, dir: dir, tabs: make(chan struct{}, RENDER_TABS_MAX)}
+
+	// Адрес для управления браузером пишется в stderr:
+	// DevTools listening on ws://127.0.0.1:9222/devtools/browser/...
+	found := make(chan string, 1)
+	go func() {
+		sc := bufio.NewScanner(stderr)
+		for sc.Scan() {
+			if v, ok := strings.CutPrefix(sc.Text(), "DevTools listening on "); ok {
+				found <- strings.TrimSpace(v)
+				break
+			}
+		}
+		close(found)
+		io.Copy(io.Discard, stderr)
+	}()
+	var ws string
+	select {
+	case ws = <-found:
+	case <-time.After(RENDER_TIMEOUT):
+	}
+	if ws == "" {
+		r.kill()
+		return nil, fmt.Errorf("Браузер не сообщил адрес для управления: \"%v\"", path)
+	}
+
+	r.conn, err = dialCDP(ws, origin)
+	if err != nil {
+		r.kill()
+		return nil, fmt.Errorf("Не удалось подключиться к браузеру: %w", err)
+	}
+
+	return r, nil
+}
+
+// Найти свободный локальный порт.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Найти установленный браузер на основе Chromium.
+// Возвращает пустую строку, если браузер не найден.
+func findChrome() string {
+	for _, v := range []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "headless_shell", "msedge"} {
+		if p, err := exec.LookPath(v); err == nil {
+			return p
+		}
+	}
+
+	paths := []string{
+		"/Applications/Chromium.app/Contents/MacOS/Chromium",
+		"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	}
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "LocalAppData"} {
+		if dir := os.Getenv(env); dir != "" {
+			paths = append(paths,
+				filepath.Join(dir, "Google", "Chrome", "Application", "chrome.exe"),
+				filepath.Join(dir, "Chromium", "Application", "chrome.exe"),
+				filepath.Join(dir, "Microsoft", "Edge", "Application", "msedge.exe"),
+			)
+		}
+	}
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+
+	return ""
+}
+
+// Отрисовать страницу в новой вкладке браузера.
+// Страница считается отрисованной после события load и паузы
+// в сетевых запросах RENDER_IDLE, но не дольше RENDER_TIMEOUT.
+func (r *ChromeRenderer) Render(ctx context.Context, u *url.URL) (*Rendered, error) {
+	select {
+	case r.tabs <- struct{}{}:
+		defer func() { <-r.tabs }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// Новая вкладка:
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := r.conn.call(ctx, "", "Target.createTarget", map[string]any{"url": "about:blank"}, &target); err != nil {
+		return nil, err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		r.conn.call(ctx, "", "Target.closeTarget", map[string]any{"targetId": target.TargetID}, nil)
+	}()
+	var attach struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := r.conn.call(ctx, "", "Target.attachToTarget", map[string]any{"targetId": target.TargetID, "flatten": true}, &attach); err != nil {
+		return nil, err
+	}
+	tab := r.conn.attach(attach.SessionID)
+	defer r.conn.detach(attach.SessionID)
+
+	// Загрузка страницы:
+	for _, m := range []string{"Page.enable", "Network.enable"} {
+		if err := r.conn.call(ctx, tab.id, m, nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	var nav struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := r.conn.call(ctx, tab.id, "Page.navigate", map[string]any{"url": u.String()}, &nav); err != nil {
+		return nil, err
+	}
+	if nav.ErrorText != "" {
+		return nil, fmt.Errorf("Ошибка загрузки страницы в браузере: %v", nav.ErrorText)
+	}
+
+	// Ожидание завершения сетевых запросов:
+	timeout := time.NewTimer(RENDER_TIMEOUT)
+	defer timeout.Stop()
+	tick := time.NewTicker(RENDER_IDLE / 5)
+	defer tick.Stop()
+	var since time.Time
+WAIT:
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-r.conn.done:
+			return nil, r.conn.err
+		case <-timeout.C:
+			break WAIT
+		case <-tick.C:
+			if !tab.idle() {
+				since = time.Time{}
+			} else if since.IsZero() {
+				since = time.Now()
+			} else if time.Since(since) >= RENDER_IDLE {
+				break WAIT
+			}
+		}
+	}
+
+	// Итоговый DOM:
+	var dom struct {
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+	}
+	expr := `(document.doctype ? new XMLSerializer().serializeToString(document.doctype) + "\n" : "") + document.documentElement.outerHTML`
+	if err := r.conn.call(ctx, tab.id, "Runtime.evaluate", map[string]any{"expression": expr, "returnByValue": true}, &dom); err != nil {
+		return nil, err
+	}
+
+	return &Rendered{HTML: []byte(dom.Result.Value), Requests: tab.urls()}, nil
+}
+
+// Закрыть браузер и удалить его временный профиль.
+func (r *ChromeRenderer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	r.conn.call(ctx, "", "Browser.close", nil, nil)
+	r.conn.ws.Close()
+
+	exited := make(chan struct{})
+	go func() {
+		r.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(time.Second * 5):
+		r.cmd.Process.Kill()
+		<-exited
+	}
+
+	return os.RemoveAll(r.dir)
+}
+
+// Завершить процесс браузера, который не удалось подключить.
+func (r *ChromeRenderer) kill() {
+	r.cmd.Process.Kill()
+	r.cmd.Wait()
+	os.RemoveAll(r.dir)
+}
+
+// Сообщение протокола Chrome DevTools
+type cdpMessage struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Соединение с браузером по протоколу Chrome DevTools.
+// Вкладки работают через одно соединение, сообщения вкладки
+// помечены её сессией. См.: Target.attachToTarget, flatten
+type cdpConn struct {
+	ws      *websocket.Conn
+	mu      sync.Mutex                // Блокировка записи и списков
+	id      int64                     // Последний номер команды
+	pending map[int64]chan cdpMessage // Ожидающие ответа команды
+	tabs    map[string]*cdpTab        // Вкладки по сессии
+	done    chan struct{}             // Закрывается при обрыве соединения
+	err     error                     // Причина обрыва соединения
+}
+
+// Подключиться к браузеру.
+// Origin должен совпадать с разрешённым при запуске: --remote-allow-origins
+func dialCDP(ws string, origin string) (*cdpConn, error) {
+	conn, err := websocket.Dial(ws, "", origin)
+	if err != nil {
+		return nil, err
+	}
+	conn.MaxPayloadBytes = 256 << 20
+
+	c := &cdpConn{
+		ws:      conn,
+		pending: make(map[int64]chan cdpMessage),
+		tabs:    make(map[string]*cdpTab),
+		done:    make(chan struct{}),
+	}
+	go c.read()
+
+	return c, nil
+}
+
+// Чтение сообщений браузера до обрыва соединения.
+func (c *cdpConn) read() {
+	for {
+		var m cdpMessage
+		if err := websocket.JSON.Receive(c.ws, &m); err != nil {
+			c.err = fmt.Errorf("Соединение с браузером разорвано: %w", err)
+			close(c.done)
+			return
+		}
+
+		c.mu.Lock()
+		if m.ID != 0 {
+			if ch, ok := c.pending[m.ID]; ok {
+				delete(c.pending, m.ID)
+				ch <- m
+			}
+		} else if tab, ok := c.tabs[m.SessionID]; ok {
+			tab.event(m)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Выполнить команду браузера и дождаться ответа.
+// Для команд вкладки указывается её сессия, для браузера - пустая строка.
+func (c *cdpConn) call(ctx context.Context, session string, method string, params any, result any) error {
+	ch := make(chan cdpMessage, 1)
+	c.mu.Lock()
+	c.id++
+	id := c.id
+	c.pending[id] = ch
+	err := websocket.JSON.Send(c.ws, cdpMessage{ID: id, SessionID: session, Method: method, Params: cdpParams(params)})
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("Не удалось отправить команду браузеру: %v: %w", method, err)
+	}
+
+	select {
+	case m := <-ch:
+		if m.Error != nil {
+			return fmt.Errorf("Ошибка команды браузера: %v: %v", method, m.Error.Message)
+		}
+		if result != nil && len(m.Result) > 0 {
+			return json.Unmarshal(m.Result, result)
+		}
+		return nil
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Параметры команды в JSON. Пустой объект, если параметров нет.
+func cdpParams(params any) json.RawMessage {
+	if params == nil {
+		return json.RawMessage("{}")
+	}
+	data, _ := json.Marshal(params)
+	return data
+}
+
+// Начать приём событий вкладки.
+func (c *cdpConn) attach(session string) *cdpTab {
+	tab := &cdpTab{id: session, inflight: make(map[string]bool)}
+	c.mu.Lock()
+	c.tabs[session] = tab
+	c.mu.Unlock()
+	return tab
+}
+
+// Прекратить приём событий вкладки.
+func (c *cdpConn) detach(session string) {
+	c.mu.Lock()
+	delete(c.tabs, session)
+	c.mu.Unlock()
+}
+
+// Состояние вкладки браузера
+type cdpTab struct {
+	mu       sync.Mutex
+	id       string          // Сессия вкладки
+	loaded   bool            // Событие load получено
+	inflight map[string]bool // Незавершённые запросы
+	requests []string        // Адреса GET запросов страницы
+}
+
+// Обработать событие вкладки.
+func (t *cdpTab) event(m cdpMessage) {
+	var p struct {
+		RequestID string `json:"requestId"`
+		Request   struct {
+			URL    string `json:"url"`
+			Method string `json:"method"`
+		} `json:"request"`
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch m.Method {
+	case "Page.loadEventFired":
+		t.loaded = true
+	case "Network.requestWillBeSent":
+		if json.Unmarshal(m.Params, &p) == nil {
+			t.inflight[p.RequestID] = true
+			if p.Request.Method == "GET" {
+				t.requests = append(t.requests, p.Request.URL)
+			}
+		}
+	case "Network.loadingFinished", "Network.loadingFailed":
+		if json.Unmarshal(m.Params, &p) == nil {
+			delete(t.inflight, p.RequestID)
+		}
+	}
+}
+
+// Страница загружена и не выполняет сетевых запросов.
+func (t *cdpTab) idle() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.loaded && len(t.inflight) == 0
+}
+
+// Получить адреса HTTP запросов страницы без повторов.
+func (t *cdpTab) urls() []*url.URL {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]*url.URL, 0, len(t.requests))
+	seen := make(map[string]bool)
+	for _, v := range t.requests {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+		if !seen[u.String()] {
+			seen[u.String()] = true
+			res = append(res, u)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Отрисовщик для тестов: возвращает заданный DOM и запросы страницы.
+type stubRenderer struct {
+	mu     sync.Mutex
+	pages  map[string]*Rendered // DOM по пути страницы
+	calls  []string             // Отрисованные адреса
+	closed bool
+}
+
+func (r *stubRenderer) Render(ctx context.Context, u *url.URL) (*Rendered, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, u.String())
+	if res, ok := r.pages[u.Path]; ok {
+		return res, nil
+	}
+	return nil, context.DeadlineExceeded
+}
+
+func (r *stubRenderer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+// Сайт-приложение: сервер отдаёт пустой шаблон, ссылки появляются после скриптов.
+func newAppServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<!DOCTYPE html><html><head><meta charset="windows-1251"><script src="/app.js"></script></head><body><div id="app"></div></body></html>`))
+		case "/about.html":
+			w.Write([]byte(`<!DOCTYPE html><html><body><p>about</p></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/app.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Write([]byte(`document.getElementById("app").innerHTML = '<a href="/about.html">about</a>'; fetch("/api/data.json");`))
+	})
+	mux.HandleFunc("/api/data.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestScanWithRenderer(t *testing.T) {
+	srv := newAppServer(t)
+	api, _ := url.Parse(srv.URL + "/api/data.json")
+	js, _ := url.Parse(srv.URL + "/app.js")
+	r := &stubRenderer{pages: map[string]*Rendered{
+		"/": {
+			HTML:     []byte(`<!DOCTYPE html><html><head><meta charset="windows-1251"></head><body><div id="app"><a href="/about.html">about</a></div></body></html>`),
+			Requests: []*url.URL{js, api},
+		},
+	}}
+
+	s := runScanner(t, ScannerParams{URL: srv.URL, Renderer: r})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+
+	// Сохраняется отрисованный DOM в UTF-8:
+	root := findSource(t, s, srv.URL+"/")
+	html := sourceFile(t, root)
+	if !strings.Contains(html, `href="/about.html"`) || !strings.Contains(html, `charset="utf-8"`) {
+		t.Errorf("index.html: %q", html)
+	}
+	if root.Charset() != "utf-8" {
+		t.Errorf("Charset() = %q", root.Charset())
+	}
+
+	// Ссылки из DOM и запросы страницы сканируются:
+	for _, u := range []string{srv.URL + "/about.html", srv.URL + "/api/data.json", srv.URL + "/app.js"} {
+		if obj := findSource(t, s, u); obj.State() != SourceComplete {
+			t.Errorf("%v: %v", u, obj.State())
+		}
+	}
+
+	// Ошибка отрисовки - сохраняется ответ сервера:
+	about := findSource(t, s, srv.URL+"/about.html")
+	if v := sourceFile(t, about); !strings.Contains(v, "<p>about</p>") {
+		t.Errorf("about.html: %q", v)
+	}
+
+	// Переданный отрисовщик сканер не закрывает:
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		t.Error("сканер закрыл переданный отрисовщик")
+	}
+	if len(r.calls) != 2 {
+		t.Errorf("отрисовано страниц: %v, want 2: %q", len(r.calls), r.calls)
+	}
+}
+
+func TestChromeRenderer(t *testing.T) {
+	if findChrome() == "" {
+		t.Skip("браузер на основе Chromium не найден")
+	}
+	srv := newAppServer(t)
+	r, err := NewChromeRenderer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	u, _ := url.Parse(srv.URL + "/")
+	ctx, cancel := context.WithTimeout(context.Background(), RENDER_TIMEOUT)
+	defer cancel()
+	res, err := r.Render(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(res.HTML), `href="/about.html"`) {
+		t.Errorf("DOM без ссылки из скрипта: %q", res.HTML)
+	}
+
+	found := false
+	for _, v := range res.Requests {
+		found = found || v.Path == "/api/data.json"
+	}
+	if !found {
+		t.Errorf("нет запроса /api/data.json: %v", res.Requests)
+	}
+
+	start := time.Now()
+	if err := r.Close(); err != nil {
+		t.Error(err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("браузер закрывается слишком долго")
+	}
+}
+
+func TestDialCDPOrigin(t *testing.T) {
+	origins := make(chan string, 1)
+	srv := httptest.NewServer(websocket.Server{
+		Handshake: func(c *websocket.Config, r *http.Request) error {
+			origins <- r.Header.Get("Origin")
+			return nil
+		},
+		Handler: func(c *websocket.Conn) { io.Copy(io.Discard, c) },
+	})
+	defer srv.Close()
+
+	origin := "http://127.0.0.1:9222"
+	c, err := dialCDP("ws"+strings.TrimPrefix(srv.URL, "http")+"/devtools/browser/x", origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.ws.Close()
+	if v := <-origins; v != origin {
+		t.Errorf("Origin = %q, want %q", v, origin)
+	}
+}
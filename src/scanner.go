@@ -2,15 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"math"
 	"mime"
 	"net/http"
 	"net/url"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -41,6 +43,12 @@ func (v ScannerState) String() string {
 		return "Сканирование"
 	case ScannerComplete:
 		return "Сканирование завершено"
+	case ScannerPaused:
+		return "Сканирование приостановлено"
+	case ScannerStopped:
+		return "Сканирование остановлено"
+	case ScannerRendererError:
+		return "Ошибка: Не удалось запустить браузер для отрисовки страниц"
 	default:
 		return "Unknown"
 	}
@@ -96,6 +104,21 @@ const (
 
 	// Готово
 	ScannerComplete
+
+	// Сканирование приостановлено.
+	// См.: Scanner.Pause(), Scanner.Resume()
+	ScannerPaused
+
+	// Сканирование остановлено до завершения.
+	// Конечное состояние сканера после вызова Scanner.Stop() или
+	// отмены контекста, переданного в Scanner.Start(). Журнал и
+	// отчёты сохраняются так же, как и при обычном завершении.
+	ScannerStopped
+
+	// Не удалось запустить браузер для отрисовки страниц.
+	// Конечное состояние сканера. Установите браузер или
+	// запустите сканер без отрисовки. См.: ScannerParams.Render
+	ScannerRendererError
 )
 
 // Параметры для запуска сканера
@@ -104,6 +127,36 @@ type ScannerParams struct {
 	// Исходный URL, заданный пользователем при сканирований.
 	URL string
 
+	// Базовый каталог для вывода данных: папки сайта, журнала
+	// и отчётов. По умолчанию: текущий рабочий каталог.
+	OutputDir string
+
+	// Шаблон расположения папки сайта внутри OutputDir, например:
+	// "{host}/{date}". По умолчанию: "{host}".
+	// Подстановки описаны в методе Scanner.expandLayout().
+	Layout string
+
+	// Политика имён файлов для сегментов пути с символами вне ASCII:
+	// оставить UTF-8 или записать процентным кодированием.
+	// По умолчанию: FileNamesUTF8
+	FileNames FileNamePolicy
+
+	// Путь к файлу журнала. По умолчанию: <OutputDir>/<host>.log
+	// Отчёты сохраняются рядом с журналом под тем же именем.
+	LogFile string
+
+	// Формат вывода файлов сайта: папка или архив.
+	// Архив создаётся по пути папки сайта с расширением: <host>.zip, ...
+	OutputFormat OutputFormat
+
+	// Дополнительно писать запросы и ответы сервера в WARC 1.1 архив
+	// с индексом CDX: <host>.warc.gz, <host>.cdx
+	WARC bool
+
+	// Дедупликация файлов сайта с одинаковым содержимым.
+	// Содержимое сравнивается по хешу SHA-256.
+	Dedup DedupMode
+
 	// Удалить все старые данные от предыдущего сканирования,
 	// если они имеются.
 	ReplaceOutDir bool
@@ -119,29 +172,130 @@ type ScannerParams struct {
 	// ответ, отличный от этих кодов:
 	//   * 503 Превышение кол-ва запросов. (Рано или поздно сервер сдастся)
 	RepeatsMax int
+
+	// Режим проверки ссылок.
+	// Ресурсы запрашиваются и анализируются, но не сохраняются
+	// на диск, папка для данных сайта не создаётся. По завершению
+	// рядом с журналом сохраняется отчёт о битых ссылках:
+	// <host>.links.txt и <host>.links.json
+	LinkCheck bool
+
+	// Проверять доступность внешних ссылок запросом HEAD,
+	// вместо их пропуска. Тело внешних ресурсов не скачивается.
+	CheckExternal bool
+
+	// Минимальный уровень записей журнала.
+	// По умолчанию: slog.LevelInfo. Отладочные записи о каждой
+	// найденной ссылке пишутся с уровнем slog.LevelDebug.
+	LogLevel slog.Level
+
+	// Писать журнал в формате JSON вместо текстового "ключ=значение".
+	LogJSON bool
+
+	// Куда писать журнал. Если не задано, журнал пишется в файл LogFile.
+	LogWriter io.Writer
+
+	// Готовый журнал для записи событий сканера. Если задан,
+	// параметры LogLevel, LogJSON и LogWriter не используются.
+	Logger *slog.Logger
+
+	// Форматы отчётов, сохраняемых по завершению сканирования.
+	// Файлы пишутся рядом с журналом: <host>.report.json, ...
+	// Пустой список - отчёты не сохраняются.
+	Reports []ReportFormat
+
+	// Форматы выгрузки графа ссылок, сохраняемого по завершению
+	// сканирования. Файлы пишутся рядом с журналом: <host>.graph.dot, ...
+	// Пустой список - граф не сохраняется.
+	Graphs []GraphFormat
+
+	// Правила извлечения ссылок из HTML.
+	// Если не задано, используются правила по умолчанию. Чтобы
+	// дополнить их, добавьте свои правила к DefaultExtractRules().
+	ExtractRules []ExtractRule
+
+	// Настройки извлечения ссылок из JavaScript.
+	// Если не задано, используются настройки по умолчанию: DefaultJSParams()
+	JS *JSParams
+
+	// Не загружать карты исходного кода: *.js.map, *.css.map и ссылки
+	// из комментариев sourceMappingURL. Карты нужны только для отладки
+	// и могут раскрывать исходный код сайта.
+	NoSourceMaps bool
+
+	// Кодировка по умолчанию для страниц, в которых она не объявлена
+	// и текст не является корректным UTF-8. Например: "windows-1251".
+	// Если не задано, используется DEFAULT_CHARSET.
+	Charset string
+
+	// Сохранять текстовые ресурсы в UTF-8 вместо кодировки сервера.
+	// Объявление кодировки в <meta> и @charset заменяется на UTF-8.
+	// По умолчанию файлы сохраняются без изменений, как получены.
+	Transcode bool
+
+	// Отрисовывать HTML страницы сайта в браузере Chromium без окна.
+	// Вместо ответа сервера сохраняется DOM после выполнения скриптов,
+	// а все запросы страницы добавляются в сканирование. Нужно для
+	// одностраничных приложений. Замедляет сканирование.
+	Render bool
+
+	// Путь к браузеру для отрисовки страниц.
+	// По умолчанию ищется установленный Chromium, Chrome или Edge.
+	Chrome string
+
+	// Свой отрисовщик страниц вместо Chromium.
+	// Если задан, страницы отрисовываются им независимо от параметра
+	// Render. Сканер не закрывает переданный отрисовщик.
+	Renderer Renderer
+
+	// Загрузчики ресурсов по протоколам URL: "http", "ftp", ...
+	// Дополняют и заменяют встроенные загрузчики, см.: DefaultFetchers().
+	// Значение nil отключает протокол. Ссылки на протоколы без загрузчика
+	// не сканируются. Позволяет копировать сайты по другим протоколам и
+	// проверять сканер на сайтах в памяти, см.: MemoryFetcher
+	Fetchers map[string]Fetcher
 }
 
 // Сканер сайта
 type Scanner struct {
 	mu         sync.RWMutex
-	workers    sync.WaitGroup // Синхронизация всех запросов
-	params     ScannerParams  // Параметры
-	state      ScannerState   // Состояние сканера
-	limiter    chan int8      // Ограничитель кол-ва параллельных запросов
-	sources    *Sources       // Список всех найденных и обрабатываемых ресурсов
-	url        *url.URL       // Распарсенный адрес исходного URL для внутренней работы
-	home       string         // Домашний каталог
-	dir        string         // Папка для сохранения ресурсов
-	dateStart  time.Time      // Дата запуска для статистики
-	dateScan   time.Time      // Дата первого запроса для статистики
-	dateFinish time.Time      // Дата завершения обработки для статистики
-	err        error          // Ошибка при работе сканера
-	threads    int            // Колв-во активных горутин
+	workers    sync.WaitGroup       // Синхронизация всех запросов
+	params     ScannerParams        // Параметры
+	state      ScannerState         // Состояние сканера
+	limiter    chan int8            // Ограничитель кол-ва параллельных запросов
+	sources    *Sources             // Список всех найденных и обрабатываемых ресурсов
+	url        *url.URL             // Распарсенный адрес исходного URL для внутренней работы
+	home       string               // Базовый каталог для вывода данных
+	logFile    string               // Путь к файлу журнала
+	out        Output               // Хранилище для сохранения файлов сайта
+	warc       *warcWriter          // WARC архив. Может быть nil
+	blobs      *blobs               // Уникальное содержимое для дедупликации
+	manifest   *manifestWriter      // Манифест загруженных ресурсов. Может быть nil
+	renderer   Renderer             // Отрисовщик страниц. Может быть nil
+	visit      func(Edge, *url.URL) // Обработчик найденных ссылок вместо сканирования. См.: Verify()
+	dir        string               // Папка для сохранения ресурсов
+	dateStart  time.Time            // Дата запуска для статистики
+	dateScan   time.Time            // Дата первого запроса для статистики
+	dateFinish time.Time            // Дата завершения обработки для статистики
+	err        error                // Ошибка при работе сканера
+	threads    int                  // Колв-во активных горутин
+	builtin    map[string]Fetcher   // Встроенные загрузчики ресурсов
+	fetchers   map[string]Fetcher   // Загрузчики ресурсов по протоколам
+	log        *slog.Logger         // Журнал сканера
+	obs        observers            // Наблюдатели за событиями сканера
+	ctx        context.Context      // Контекст работы сканера
+	cancel     context.CancelFunc   // Остановка сканера
+	resume     chan struct{}        // Закрывается при снятии с паузы. nil - сканер не на паузе
 }
 
 // Создать новый сканер
 func NewScanner() *Scanner {
-	return &Scanner{}
+	builtin := DefaultFetchers()
+	return &Scanner{
+		builtin:  builtin,
+		fetchers: builtin,
+		log:      nopLogger(),
+	}
 }
 
 // Сбросить сканер для новой работы
@@ -155,8 +309,19 @@ func (s *Scanner) reset() *Scanner {
 	s.params = ScannerParams{}
 	s.url = nil
 	s.dir = ""
+	s.home = ""
+	s.logFile = ""
+	s.out = nil
+	s.warc = nil
+	s.blobs = newBlobs()
+	s.manifest = nil
+	s.renderer = nil
 	s.err = nil
 	s.threads = 0
+	s.log = nopLogger()
+	s.ctx = nil
+	s.cancel = nil
+	s.resume = nil
 	return s
 }
 
@@ -165,25 +330,32 @@ func (s *Scanner) reset() *Scanner {
 // или для проверки текущего состояния работы сканера вы
 // можете периодически опрашивать свойство: Scanner.State().
 //
+// Отмена контекста ctx останавливает сканирование так же, как
+// вызов метода Scanner.Stop().
+//
 // Ошибка возвращается, если сканер попытаться запустить из
 // не конечных состояний:
-//   * ScannerStatePreparing - сканер выполняет подготовку,
+//   - ScannerStatePreparing - сканер выполняет подготовку,
 //     дождитесь завершения;
-//   * ScannerScanning - сканирование уже выполняется,
-//     дождитесь завершения
+//   - ScannerScanning, ScannerPaused - сканирование уже выполняется,
+//     дождитесь завершения или остановите сканер
+//
 // Остальные состояния сканера являются конечными и для них
 // может быть выполнен запуск.
-func (s *Scanner) Start(params ScannerParams) error {
+func (s *Scanner) Start(ctx context.Context, params ScannerParams) error {
 
 	// Запуск:
 	s.mu.Lock()
 	switch s.state {
-	case ScannerReady, ScannerOutputDirExist, ScannerIncorrectURL, ScannerComplete:
+	case ScannerReady, ScannerOutputDirExist, ScannerOutputDirError, ScannerIncorrectURL, ScannerComplete, ScannerStopped, ScannerRendererError:
 		s.reset()
 		s.dateStart = time.Now()
 		s.state = ScannerPreparing
 		s.params = params
+		s.fetchers = s.newFetchers()
+		s.ctx, s.cancel = context.WithCancel(ctx)
 		s.mu.Unlock()
+		s.emitScanner()
 	default:
 		v := s.state.String()
 		s.mu.Unlock()
@@ -194,105 +366,107 @@ func (s *Scanner) Start(params ScannerParams) error {
 	var work = func() {
 		var err error
 
+		// Ошибка подготовки: освобождение контекста и хранилищ.
+		var fail error
+		var failState ScannerState
+		defer func() {
+			if fail == nil {
+				return
+			}
+			s.cancel()
+			s.closeOutput()
+			s.mu.Lock()
+			s.err = fail
+			s.state = failState
+			s.dateFinish = time.Now()
+			s.mu.Unlock()
+			s.emitScanner()
+		}()
+
 		// Анализ URL:
 		s.mu.Lock()
 		s.url, err = s.parseURL(params.URL)
+		s.mu.Unlock()
 		if err != nil {
-			s.state = ScannerIncorrectURL
-			s.err = fmt.Errorf("Не удалось запустить сканер из-за ошибки разбора URL: %w", err)
-			s.dateFinish = time.Now()
-			s.mu.Unlock()
+			failState, fail = ScannerIncorrectURL, fmt.Errorf("Не удалось запустить сканер из-за ошибки разбора URL: %w", err)
 			return
 		}
-		s.mu.Unlock()
 
 		// Получение пути для вывода:
 		s.mu.Lock()
-		s.home, err = s.binPath()
+		s.home, err = s.outputDir()
+		if err == nil {
+			s.logFile, err = s.logPath()
+		}
+		var layout string
+		if err == nil {
+			layout, err = s.expandLayout(s.params.Layout)
+		}
 		if err != nil {
-			s.state = ScannerOutputDirError
-			s.err = err
-			s.dateFinish = time.Now()
 			s.mu.Unlock()
+			failState, fail = ScannerOutputDirError, err
 			return
 		}
-		s.dir = s.home + string(os.PathSeparator) + s.url.Host
+		s.dir = filepath.Join(s.home, layout)
 		s.mu.Unlock()
 
-		// Создание папки:
-		s.mu.Lock()
-		file, err := os.Stat(s.dir)
-		if err != nil {
-			if os.IsNotExist(err) {
-				// Создаём новую папку:
-				if err2 := os.Mkdir(s.dir, 0777); err2 != nil {
-					s.err = fmt.Errorf("Не удалось создать папку для данных сайта: %w", err2)
-					s.state = ScannerOutputDirError
-					s.dateFinish = time.Now()
-					s.mu.Unlock()
-					return
-				}
-			} else {
-				// Папка есть а доступа к ней нет:
-				s.err = fmt.Errorf("Ошибка доступа к папке для сохранения: %w", err)
-				s.state = ScannerOutputDirError
-				s.dateFinish = time.Now()
-				s.mu.Unlock()
+		// Запуск браузера для отрисовки страниц:
+		if s.params.Renderer != nil {
+			s.mu.Lock()
+			s.renderer = s.params.Renderer
+			s.mu.Unlock()
+		} else if s.params.Render {
+			r, err := NewChromeRenderer(s.params.Chrome)
+			if err != nil {
+				failState, fail = ScannerRendererError, err
 				return
 			}
-		} else {
-			if file.IsDir() {
-				// Папка уже существует:
-				if s.params.ReplaceOutDir {
-					if err = os.RemoveAll(s.dir); err != nil {
-						s.err = fmt.Errorf("Не удалось удалить старую папку с данными сайта: \"%v\": %w", s.dir, err)
-						s.state = ScannerOutputDirError
-						s.dateFinish = time.Now()
-						s.mu.Unlock()
-						return
-					}
-
-					// Создаём новую:
-					if err = os.Mkdir(s.dir, 0777); err != nil {
-						s.err = fmt.Errorf("Не удалось создать новую папку для данных сайта: \"%v\": %w", s.dir, err)
-						s.state = ScannerOutputDirError
-						s.dateFinish = time.Now()
-						s.mu.Unlock()
-						return
-					}
+			defer r.Close()
+			s.mu.Lock()
+			s.renderer = r
+			s.mu.Unlock()
+		}
 
-				} else {
-					s.err = fmt.Errorf("Папка для данных сайта уже существует, сперва удалите её: \"%v\"", s.dir)
-					s.state = ScannerOutputDirExist
-					s.dateFinish = time.Now()
-					s.mu.Unlock()
-					return
-				}
-			} else {
-				// Тут лежит какойто файл:
-				s.err = fmt.Errorf("Ошибка, путь для создания папки с данными сайта занят файлом: \"%v\"", s.dir)
-				s.state = ScannerOutputDirError
-				s.dateFinish = time.Now()
+		// Создание хранилища: (В режиме проверки ссылок файлы не пишутся)
+		if !s.params.LinkCheck {
+			s.mu.Lock()
+			if state, err := s.openOutput(); err != nil {
 				s.mu.Unlock()
+				failState, fail = state, err
 				return
 			}
+			s.mu.Unlock()
+		} else {
+			s.mu.Lock()
+			s.dir = ""
+			s.mu.Unlock()
 		}
-		s.mu.Unlock()
 
-		// Создание файла журнала:
+		// Создание журнала:
 		s.mu.Lock()
-		p := path.Clean(s.home + string(os.PathSeparator) + s.url.Host + ".log")
-		os.Truncate(p, 0)
-		f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0777)
-		defer f.Close()
-		if err != nil {
-			s.err = fmt.Errorf("Ошибка, не удалось создать файл для вывода логов: \"%v\"", p)
-			s.state = ScannerOutputDirError
-			s.dateFinish = time.Now()
-			s.mu.Unlock()
-			return
+		if s.params.Logger != nil {
+			s.log = s.params.Logger
+		} else {
+			w := s.params.LogWriter
+			if w == nil {
+				p := s.logFile
+				if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+					s.mu.Unlock()
+					failState, fail = ScannerOutputDirError, fmt.Errorf("Ошибка, не удалось создать папку для файла логов: \"%v\": %w", p, err)
+					return
+				}
+				f, err := os.Create(p)
+				if err != nil {
+					s.mu.Unlock()
+					failState, fail = ScannerOutputDirError, fmt.Errorf("Ошибка, не удалось создать файл для вывода логов: \"%v\": %w", p, err)
+					return
+				}
+				defer f.Close()
+				w = f
+			}
+			s.log = newLogger(w, s.params.LogLevel, s.params.LogJSON)
 		}
-		log.SetOutput(f)
+		s.log.Info("Запуск сканирования", "url", s.url.String(), "dir", s.dir)
 		s.mu.Unlock()
 
 		// Запуск сканирования:
@@ -300,20 +474,67 @@ func (s *Scanner) Start(params ScannerParams) error {
 		s.state = ScannerScanning
 		s.dateScan = time.Now()
 		s.mu.Unlock()
+		s.emitScanner()
 
 		s.workers.Add(3)
-		go s.scan(s.url)
-		go s.scan(s.rootFile(s.url, "/robots.txt"))
-		go s.scan(s.rootFile(s.url, "/sitemap.xml"))
+		go s.scan(Edge{}, s.url)
+		go s.scan(Edge{}, s.rootFile(s.url, "/robots.txt"))
+		go s.scan(Edge{}, s.rootFile(s.url, "/sitemap.xml"))
 
 		// Ожидание завершения всех потоков:
 		s.workers.Wait()
-		log.Println("\n\nПолный отчёт сканирования:\n" + scanner.Report(true))
 
 		s.mu.Lock()
-		s.state = ScannerComplete
 		s.dateFinish = time.Now()
+		stopped := s.ctx.Err() != nil
+		s.cancel()
 		s.mu.Unlock()
+
+		// Завершение записи файлов:
+		s.closeOutput()
+
+		// Сохранение отчётов:
+		for _, f := range s.params.Reports {
+			p := s.reportPath("report." + f.Ext())
+			if err := s.ExportFile(p, f); err != nil {
+				s.log.Error("Не удалось сохранить отчёт", "format", f.String(), "err", err)
+			}
+		}
+		for _, f := range s.params.Graphs {
+			p := s.reportPath("graph." + f.Ext())
+			if err := s.ExportGraphFile(p, f); err != nil {
+				s.log.Error("Не удалось сохранить граф ссылок", "format", f.String(), "err", err)
+			}
+		}
+		if s.params.LinkCheck {
+			p := s.reportPath("links")
+			if err := s.ExportLinksFile(p); err != nil {
+				s.log.Error("Не удалось сохранить отчёт о битых ссылках", "err", err)
+			}
+		}
+
+		msg := "Сканирование завершено"
+		if stopped {
+			msg = "Сканирование остановлено"
+		}
+		sum := s.Summary()
+		s.log.Info(msg,
+			"count", sum.Count,
+			"count_external", sum.CountExternal,
+			"count_internal", sum.CountInternal,
+			"size", sum.Size,
+			"size_wire", sum.SizeWire,
+			"duration", time.Duration(sum.Duration)*time.Millisecond,
+		)
+
+		s.mu.Lock()
+		if stopped {
+			s.state = ScannerStopped
+		} else {
+			s.state = ScannerComplete
+		}
+		s.mu.Unlock()
+		s.emitScanner()
 	}
 	go work()
 	return nil
@@ -322,12 +543,42 @@ func (s *Scanner) Start(params ScannerParams) error {
 // Получить путь для корневого файла, такого как: robots.txt, ...
 func (s *Scanner) rootFile(base *url.URL, file string) *url.URL {
 	u2, _ := url.Parse(base.String())
-	u2.Path = file
+	u2.Path = s.rootPath() + strings.TrimPrefix(file, "/")
 	return u2
 }
 
-// Сканирование URL в отдельном потоке
-func (s *Scanner) scan(url *url.URL) {
+// Запустить сканирование найденной ссылки в отдельном потоке.
+func (s *Scanner) follow(e Edge, url *url.URL) {
+	if s.visit != nil {
+		s.visit(e, url)
+		return
+	}
+	if url != nil {
+		if err := normalizeURL(url); err != nil {
+			s.log.Debug("Пропуск ссылки", "url", url.String(), "err", err)
+			return
+		}
+	}
+	if url != nil && url.RawQuery != "" && e.From != nil && strings.Contains(e.From.Mime(), "text/html") {
+		url.RawQuery = encodeQuery(url.RawQuery, e.From.Charset())
+	}
+	if s.params.NoSourceMaps && url != nil && isSourceMap(e, url) {
+		s.log.Debug("Пропуск карты исходного кода", "url", url.String())
+		return
+	}
+	if s.warc != nil && e.From != nil && url != nil {
+		e.From.mu.Lock()
+		e.From.links = append(e.From.links, warcOutlink(e, url))
+		e.From.mu.Unlock()
+	}
+
+	s.workers.Add(1)
+	go s.scan(e, url)
+}
+
+// Сканирование URL в отдельном потоке.
+// Параметр e - ссылка, по которой найден ресурс. Пустая для исходного URL.
+func (s *Scanner) scan(e Edge, url *url.URL) {
 	defer s.workers.Done()
 	defer func() {
 		s.mu.Lock()
@@ -344,30 +595,43 @@ func (s *Scanner) scan(url *url.URL) {
 	s.mu.Unlock()
 
 	// Добавляем ресурс:
-	obj, ok := s.sources.Add(url)
+	obj, ok := s.sources.Add(url, e)
 	if ok == false {
 		return
 	}
+	defer func() {
+		obj.mu.Lock()
+		obj.dateFinish = time.Now()
+		obj.mu.Unlock()
+		s.record(obj)
+	}()
+	s.emitSource(EventSourceAdd, obj)
 
 	// Пропуск слишком длинных URL: (Иногда туда попадают куски двоичных данных)
 	if len(url.String()) > 1000 {
 		obj.mu.Lock()
 		obj.state = SourceSkip
-		obj.err = fmt.Errorf("Пропуск ссылки (Слишком длинная): " + string([]rune(url.String())[0:80]) + "...")
-		log.Printf(obj.err.Error())
+		obj.err = fmt.Errorf("Пропуск ссылки (Слишком длинная): %v...", string([]rune(url.String())[0:80]))
 		obj.mu.Unlock()
+		s.emitState(obj)
+		s.log.Warn("Пропуск ссылки: слишком длинная", "url", string([]rune(url.String())[0:80]), "length", len(url.String()))
 		return
 	}
 
 	// Логируем ссылку:
-	log.Println("Новая ссылка: " + url.String())
+	if e.From != nil {
+		s.logSource(slog.LevelDebug, obj, "Новая ссылка", "from", e.From.url.String(), "tag", e.Tag, "attr", e.Attr)
+	} else {
+		s.logSource(slog.LevelDebug, obj, "Новая ссылка")
+	}
 
 	// Пропуск не интересных ресурсов - телефоны, почты, фтп и т.д.:
 	obj.mu.Lock()
 	if obj.isInteresting == false {
 		obj.state = SourceSkip
 		obj.mu.Unlock()
-		log.Println("Пропуск ссылки (Не интересная): " + url.String())
+		s.emitState(obj)
+		s.logSource(slog.LevelDebug, obj, "Пропуск ссылки: не интересная")
 		return
 	}
 	obj.mu.Unlock()
@@ -375,25 +639,66 @@ func (s *Scanner) scan(url *url.URL) {
 	// Пропуск внешних ресурсов:
 	obj.mu.Lock()
 	if obj.isExternal {
+		if s.params.CheckExternal && (url.Scheme == "http" || url.Scheme == "https") {
+			obj.mu.Unlock()
+			s.check(obj)
+			return
+		}
 		obj.state = SourceSkip
 		obj.mu.Unlock()
-		log.Printf("Пропуск ссылки (Внешняя): %v\n", url.String())
+		s.emitState(obj)
+		s.logSource(slog.LevelDebug, obj, "Пропуск ссылки: внешняя")
 		return
 	}
 	obj.mu.Unlock()
 
 	// Ресурс ранее не обрабатывался
 	// Ожидаем нашу очередь на запрос:
-	s.limiter <- 0
+	if err := s.acquire(); err != nil {
+		s.cancelSource(obj, err)
+		return
+	}
+	obj.mu.Lock()
+	obj.dateStart = time.Now()
+	obj.mu.Unlock()
 
 	// Запрос ресурса:
 	var body []byte
+	var capture string // Идентификатор записи ответа в WARC архиве
+	var date time.Time // Дата захвата ответа
 	for {
+		if err := s.wait(); err != nil {
+			<-s.limiter
+			s.cancelSource(obj, err)
+			return
+		}
+
 		obj.mu.Lock()
 		obj.state = SourceRequest
 		obj.mu.Unlock()
+		s.emitState(obj)
+
+		req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, url.String(), nil)
+		if err != nil {
+			obj.mu.Lock()
+			obj.state = SourceRequestError
+			obj.err = err
+			obj.mu.Unlock()
+			s.emitState(obj)
+			<-s.limiter
+			s.logSource(slog.LevelError, obj, "Пропуск ссылки: некорректный запрос", "err", err)
+			return
+		}
+		req.Header.Set("Accept-Encoding", ACCEPT_ENCODING)
+		date = time.Now()
+		resp, err := s.fetch(req)
 
-		resp, err := http.Get(url.String())
+		// Остановка сканера:
+		if err != nil && s.ctx.Err() != nil {
+			<-s.limiter
+			s.cancelSource(obj, s.ctx.Err())
+			return
+		}
 
 		// Сетевая ошибка:
 		if err != nil {
@@ -405,18 +710,21 @@ func (s *Scanner) scan(url *url.URL) {
 				obj.state = SourceRequestError
 				obj.err = err
 				obj.mu.Unlock()
+				s.emitState(obj)
 
 				if resp != nil && resp.Body != nil {
 					resp.Body.Close()
 				}
 				<-s.limiter
-				log.Println("Пропуск ссылки (Исчерпан лимит попыток запроса): " + url.String())
+				s.logSource(slog.LevelError, obj, "Пропуск ссылки: исчерпан лимит попыток запроса", "err", err)
 				return
 			} else {
 				// Повтор попытки:
 				obj.state = SourceRequestWaitRepeat
 				obj.err = err
 				obj.mu.Unlock()
+				s.emitState(obj)
+				s.logSource(slog.LevelWarn, obj, "Ошибка запроса, повторная попытка", "err", err)
 
 				if resp != nil && resp.Body != nil {
 					resp.Body.Close()
@@ -425,18 +733,33 @@ func (s *Scanner) scan(url *url.URL) {
 			}
 		}
 
+		// Заголовки и адрес после перенаправлений:
+		obj.mu.Lock()
+		obj.header = resp.Header.Clone()
+		if resp.Request.URL.String() != url.String() {
+			obj.finalURL = resp.Request.URL
+		}
+		obj.mu.Unlock()
+
 		// Обработка некоторых HTTP кодов
 		// Превышение кол-ва запросов:
 		if resp.StatusCode == 503 {
 			obj.mu.Lock()
 			obj.state = SourceRequestWaitRepeat
-			obj.err = fmt.Errorf(resp.Status)
+			obj.status = resp.StatusCode
+			obj.err = fmt.Errorf("%v", resp.Status)
 			obj.repeats++
 			try := obj.repeats
 			obj.mu.Unlock()
+			s.emitState(obj)
+			s.logSource(slog.LevelWarn, obj, "Превышение кол-ва запросов, повторная попытка", "try", try)
 
 			resp.Body.Close()
-			s.waitRepeat(try)
+			if err := s.waitRepeat(try); err != nil {
+				<-s.limiter
+				s.cancelSource(obj, err)
+				return
+			}
 			continue
 		}
 
@@ -444,44 +767,75 @@ func (s *Scanner) scan(url *url.URL) {
 		if resp.StatusCode >= 400 {
 			obj.mu.Lock()
 			obj.state = SourceRequestError
-			obj.err = fmt.Errorf(resp.Status)
+			obj.status = resp.StatusCode
+			obj.err = fmt.Errorf("%v", resp.Status)
 			obj.mu.Unlock()
+			s.emitState(obj)
 
+			// Ответы с ошибкой тоже сохраняются в WARC архив:
+			if s.warc != nil {
+				if raw, err := ioutil.ReadAll(resp.Body); err == nil {
+					s.archive(obj, resp, raw, date)
+				}
+			}
 			resp.Body.Close()
 			<-s.limiter
-			log.Printf("Пропуск ссылки (%v): %v\n", resp.Status, url.String())
+			s.logSource(slog.LevelWarn, obj, "Пропуск ссылки: ошибка HTTP")
 			return
 		}
 
 		// Заголовки:
 		obj.mu.Lock()
-		if resp.ContentLength > 0 {
-			obj.size = resp.ContentLength
-		}
 		obj.state = SourceDownload
+		obj.status = resp.StatusCode
 		obj.mu.Unlock()
-
-		// Скачиваем всё тело:
-		body, err = ioutil.ReadAll(resp.Body)
+		s.emitState(obj)
+
+		// Скачиваем и распаковываем всё тело:
+		// Для WARC архива копия тела сохраняется в том виде, как передана по сети.
+		var raw bytes.Buffer
+		wire := &countReader{r: resp.Body}
+		if s.warc != nil {
+			wire.r = io.TeeReader(resp.Body, &raw)
+		}
+		dec, release, err := decodeBody(wire, resp.Header.Get("Content-Encoding"))
+		if err == nil {
+			body, err = ioutil.ReadAll(dec)
+			release()
+		}
 		resp.Body.Close()
+		if err != nil && s.ctx.Err() != nil {
+			<-s.limiter
+			s.cancelSource(obj, s.ctx.Err())
+			return
+		}
 		obj.mu.Lock()
 		obj.size = int64(len(body))
+		obj.sizeWire = wire.n
+		obj.hash = fmt.Sprintf("%x", sha256.Sum256(body))
 		if err != nil {
 			obj.err = err
 			obj.repeats++
-			if obj.repeats > s.params.RepeatsMax {
+			if permanent := isPermanentDecodeError(err); permanent || obj.repeats > s.params.RepeatsMax {
 				obj.state = SourceDownloadError
 				obj.mu.Unlock()
+				s.emitState(obj)
 				<-s.limiter
-				log.Printf("Пропуск ссылки (Ошибка сачивания тела, исчерпаны попытки): %v, %v\n", url.String(), err.Error())
+				msg := "Пропуск ссылки: ошибка скачивания тела, исчерпаны попытки"
+				if permanent {
+					msg = "Пропуск ссылки: не удалось распаковать тело"
+				}
+				s.logSource(slog.LevelError, obj, msg, "err", err)
 				return
 			} else {
 				obj.state = SourceRequest
 				obj.mu.Unlock()
+				s.emitState(obj)
 				continue
 			}
 		}
 		obj.mu.Unlock()
+		capture = s.archive(obj, resp, raw.Bytes(), date)
 		break
 	}
 	<-s.limiter
@@ -490,6 +844,7 @@ func (s *Scanner) scan(url *url.URL) {
 	obj.mu.Lock()
 	obj.state = SourceRead
 	obj.mu.Unlock()
+	s.emitState(obj)
 
 	// Определяем mime тип ресурса, запускаем анализ тела для поиска ссылок:
 	mim := http.DetectContentType(body)
@@ -497,29 +852,56 @@ func (s *Scanner) scan(url *url.URL) {
 	obj.mime = mim
 	obj.mu.Unlock()
 
-	// All mime types:
-	// https://www.iana.org/assignments/media-types/media-types.xhtml
-	if strings.Contains(mim, "text/html") {
-		s.readHTML(obj, body)
-		s.readTXT(obj, body)
-	} else if strings.Contains(mim, "application/octet-stream") ||
-		strings.Contains(mim, "model") ||
-		strings.Contains(mim, "font") ||
-		strings.Contains(mim, "image") ||
-		strings.Contains(mim, "video") ||
-		strings.Contains(mim, "audio") ||
-		strings.Contains(mim, "application/ogg") {
-		// Игнорируем анализ этих типов..
+	// Отрисовка страницы в браузере: (Сохраняется итоговый DOM в UTF-8)
+	// Текст остальных ресурсов перекодируется в UTF-8 только для анализа,
+	// на диск пишется ответ сервера без изменений:
+	var text []byte
+	var rendered bool
+	if s.renderer != nil && strings.Contains(mim, "text/html") && !obj.isExternal {
+		body, rendered = s.render(obj, body)
+	}
+	if rendered {
+		text = body
 	} else {
-		s.readTXT(obj, body)
+		text = s.decodeText(obj, mim, body)
+		if s.params.Transcode && obj.Charset() != "" && obj.Charset() != "utf-8" {
+			text = s.transcode(obj, mim, text)
+			body = text
+		}
+	}
+
+	s.readBody(obj, mim, text)
+
+	// Найденные ссылки пишутся в метаданные WARC архива:
+	s.archiveMeta(obj, capture, date)
+
+	// В режиме проверки ссылок ресурс не сохраняется:
+	if s.params.LinkCheck {
+		obj.mu.Lock()
+		obj.state = SourceChecked
+		obj.mu.Unlock()
+		s.emitState(obj)
+		return
+	}
+
+	// Файлы сайта пишутся только в WARC архив:
+	if s.out == nil {
+		obj.mu.Lock()
+		obj.state = SourceComplete
+		obj.mu.Unlock()
+		s.emitState(obj)
+		s.logSource(slog.LevelDebug, obj, "Ресурс сохранён в WARC архив")
+		return
 	}
 
 	obj.mu.Lock()
 	obj.state = SourceSave
 	obj.mu.Unlock()
+	s.emitState(obj)
 
 	// Получаем путь и имя файла для записи файла на диск:
-	path, name := filepath.Split(obj.url.Path)
+	path, name := filepath.Split(s.sitePath(obj.url))
+	orig := name
 	if name == "" {
 		name = "/index.html"
 	} else if filepath.Ext(name) == "" {
@@ -531,40 +913,64 @@ func (s *Scanner) scan(url *url.URL) {
 		}
 	}
 
-	// Из-за возможных ошибок анализа файл не должен быть выше корневой директорий или не в ней:
-	if err := s.isParentPath(s.dir, s.dir+path+name); err != nil {
-		obj.mu.Lock()
-		obj.state = SourceSaveError
-		obj.err = err
-		obj.mu.Unlock()
-		log.Printf("Пропуск ссылки (Некорректный путь для сохранения файла): %v, %v\n", url.String(), err.Error())
-		return
+	// Страницы с параметрами запроса пишутся в отдельные файлы: "list@p=1.html"
+	if obj.url.RawQuery != "" && strings.Contains(mim, "text/html") {
+		ext := filepath.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "@" + queryName(obj.url.RawQuery) + ext
 	}
 
-	// Создаём путь:
-	if err := os.MkdirAll(s.dir+path, 0777); err != nil {
+	// Имена, допустимые во всех файловых системах:
+	path = fileSegments(path, s.params.FileNames)
+	name = fileSegments(name, s.params.FileNames)
+
+	// Из-за возможных ошибок анализа файл не должен быть выше корневой директорий или не в ней:
+	if err := s.isParentPath(s.dir, s.dir+path+name); err != nil {
 		obj.mu.Lock()
 		obj.state = SourceSaveError
 		obj.err = err
 		obj.mu.Unlock()
-		log.Printf("Пропуск ссылки (Не удалось создать путь для сохранения файла в системе): %v, %v\n", url.String(), err.Error())
+		s.emitState(obj)
+		s.logSource(slog.LevelError, obj, "Пропуск ссылки: некорректный путь для сохранения файла", "err", err)
 		return
 	}
 
-	// Пишем файл:
-	if err := os.WriteFile(s.dir+path+name, body, 0777); err != nil {
+	// Пишем файл: (Недостающие папки создаются хранилищем)
+	file, err := s.store(obj, path+name, body)
+	if err != nil {
 		obj.mu.Lock()
 		obj.state = SourceSaveError
 		obj.err = err
 		obj.mu.Unlock()
-		log.Printf("Пропуск ссылки (Не удалось сохранить файл): %v, %v\n", url.String(), err.Error())
+		s.emitState(obj)
+		s.logSource(slog.LevelError, obj, "Пропуск ссылки: не удалось сохранить файл", "err", err)
 		return
 	}
 
 	// Ресурс успешно обработан:
 	obj.mu.Lock()
 	obj.state = SourceComplete
+	obj.file = s.out.Path(file)
+	obj.path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+file)), "/")
+	obj.renamed = name != orig
 	obj.mu.Unlock()
+	s.emitState(obj)
+	s.logSource(slog.LevelDebug, obj, "Ресурс сохранён", "file", obj.File())
+}
+
+// Получить часть имени файла для параметров запроса.
+// Недопустимые в именах файлов символы заменяются на "_",
+// слишком длинные запросы заменяются хешем.
+func queryName(query string) string {
+	if len(query) > 100 {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(query)))[:16]
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, query)
 }
 
 func (s *Scanner) isParentPath(parent string, child string) error {
@@ -577,7 +983,6 @@ func (s *Scanner) isParentPath(parent string, child string) error {
 	if len(c) < len(p) {
 		return fmt.Errorf("Получен некорректный путь файла: \"%v\" для записи в: \"%v\" - дочерний путь короче родительского", child, parent)
 	}
-	log.Println(p, c)
 	for i := range p {
 		if p[i] != c[i] {
 			return fmt.Errorf("Получен некорректный путь файла: \"%v\" для записи в: \"%v\" - файл не в родительском каталоге", filepath.Clean(child), filepath.Clean(parent))
@@ -587,29 +992,43 @@ func (s *Scanner) isParentPath(parent string, child string) error {
 	return nil
 }
 
-// Ждать следующую попытку
-func (s *Scanner) waitRepeat(try int) {
+// Ждать следующую попытку.
+// Возвращает ошибку, если сканер остановлен во время ожидания.
+func (s *Scanner) waitRepeat(try int) error {
 	if try < 1 {
-		return
+		return nil
 	}
 
+	var d time.Duration
 	switch try {
 	case 1:
-		time.Sleep(time.Millisecond * 200)
+		d = time.Millisecond * 200
 	case 2:
-		time.Sleep(time.Millisecond * 500)
+		d = time.Millisecond * 500
 	case 3:
-		time.Sleep(time.Millisecond * 1000)
+		d = time.Millisecond * 1000
 	case 4:
-		time.Sleep(time.Millisecond * 2000)
+		d = time.Millisecond * 2000
 	case 5:
-		time.Sleep(time.Millisecond * 5000)
+		d = time.Millisecond * 5000
 	default:
-		time.Sleep(time.Millisecond * 8000)
+		d = time.Millisecond * 8000
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
 	}
 }
 
-// Прочитать тело файла для поиска и сканирования других ссылок
+// Прочитать тело файла для поиска и сканирования других ссылок.
+// Кроме тегов по правилам, адреса ищутся в тексте страницы. Код
+// <script> и <style> и комментарии в этот поиск не входят: скрипты
+// и стили разбираются своими правилами. См.: readText()
 func (s *Scanner) readHTML(obj *Source, body []byte) {
 	doc, err := html.Parse(bytes.NewReader(body))
 	if err != nil {
@@ -619,26 +1038,32 @@ func (s *Scanner) readHTML(obj *Source, body []byte) {
 		return
 	}
 
-	// Проходим по всем тегам:
+	// Проходим по всем тегам и ищем ссылки по правилам:
+	rules := s.extractRules()
+	var text bytes.Buffer
 	var f func(n *html.Node)
 	f = func(n *html.Node) {
-		for i := range n.Attr {
-			a := &n.Attr[i]
-
-			// Ищем любые ссылки в теге:
-			var links []*url.URL
-			switch a.Key {
-			case "src", "href":
-				links = s.parseSrc(n, a)
-			case "srcset", "data-srcset":
-				links = s.parseSrcset(n, a)
-			}
+		if n.Type == html.TextNode && (n.Parent == nil || n.Parent.Data != "script" && n.Parent.Data != "style") {
+			text.WriteString(n.Data)
+			text.WriteByte('\n')
+		}
+		if n.Type == html.ElementNode {
+			for _, r := range rules {
+				if (r.Tag != "*" && r.Tag != n.Data) || (r.Match != nil && !r.Match(n)) {
+					continue
+				}
 
-			// Запуск сканирования всех найденных ссылок:
-			if len(links) > 0 {
-				for j := 0; j < len(links); j++ {
-					s.workers.Add(1)
-					go s.scan(links[j])
+				// Содержимое тега:
+				if r.Attr == "" {
+					s.extract(obj, Edge{From: obj, Tag: n.Data}, r.Kind, n, &html.Attribute{Val: nodeText(n)})
+					continue
+				}
+
+				// Атрибут:
+				for i := range n.Attr {
+					if a := &n.Attr[i]; a.Key == r.Attr {
+						s.extract(obj, Edge{From: obj, Tag: n.Data, Attr: a.Key}, r.Kind, n, a)
+					}
 				}
 			}
 		}
@@ -647,48 +1072,35 @@ func (s *Scanner) readHTML(obj *Source, body []byte) {
 		}
 	}
 	f(doc)
+
+	s.readText(obj, text.Bytes())
 }
 
 // Обработать ссылки в атрибуте "src" любого тега
-func (s *Scanner) parseSrc(n *html.Node, a *html.Attribute) []*url.URL {
+// Относительные ссылки разрешаются от адреса страницы obj.
+func (s *Scanner) parseSrc(obj *Source, n *html.Node, a *html.Attribute) []*url.URL {
 	u, e := url.Parse(a.Val)
 	if e != nil {
-		log.Printf("Ошибка разбора src ссылки в теге: <%v ... %v=\"%v\" ... >: %v", n.Data, a.Key, a.Val, e.Error())
+		s.log.Debug("Ошибка разбора ссылки в атрибуте", "tag", n.Data, "attr", a.Key, "value", a.Val, "err", e)
 		return nil
 	}
 
 	// Относительные ссылки в абсолютные, чтоб программа могла
 	// сравнить домен ссылки с родным: (Только внутри программы)
-	if u.IsAbs() == false {
-		u.Scheme = s.url.Scheme
-		u.Host = s.url.Host
+	base := s.url
+	if obj != nil && obj.url != nil {
+		base = obj.url
 	}
 
-	return []*url.URL{u}
+	return []*url.URL{base.ResolveReference(u)}
 }
 
 // Обработать ссылки в атрибуте "srcset" любого тега
-func (s *Scanner) parseSrcset(n *html.Node, a *html.Attribute) []*url.URL {
-	arr := strings.Split(a.Val, ",")
+func (s *Scanner) parseSrcset(obj *Source, n *html.Node, a *html.Attribute) []*url.URL {
+	arr := ParseSrcset(a.Val)
 	res := make([]*url.URL, 0, len(arr))
 	for i := 0; i < len(arr); i++ {
-		arr2 := strings.Split(arr[i], " ")
-		for j := 0; j < len(arr2); j++ {
-			u, e := url.Parse(arr[i])
-			if e != nil {
-				log.Printf("Ошибка разбора %v-ого значения атрибута в srcset ссылке тега: <%v ... %v=\"%v\" ... >: %v", i, n.Data, a.Key, a.Val, e.Error())
-				continue
-			}
-
-			// Относительные ссылки в абсолютные, чтоб программа могла
-			// сравнить домен ссылки с родным: (Только внутри программы)
-			if u.IsAbs() == false {
-				u.Scheme = s.url.Scheme
-				u.Host = s.url.Host
-			}
-
-			res = append(res, u)
-		}
+		res = append(res, s.parseSrc(obj, n, &html.Attribute{Key: a.Key, Val: arr[i].URL})...)
 	}
 
 	return res
@@ -696,38 +1108,50 @@ func (s *Scanner) parseSrcset(n *html.Node, a *html.Attribute) []*url.URL {
 
 func (s *Scanner) readTXT(obj *Source, body []byte) {
 
-	// Шаблоны для CSS:
-	// url(...)
-	// URL(...)
-	// url('...')
-	// url("...")
-	// url(`...`)
-	// url (...)
-	// url  (...)
-	reg := regexp.MustCompile(`(?i)url *\(`)
-	res := reg.FindAllIndex(body, -1)
-	for i := 0; i < len(res); i++ {
-		if url := s.searchLink(body, res[i][1]); url != nil {
-			s.workers.Add(1)
-			go s.scan(url)
-		}
-	}
+	// Шаблоны для CSS: url(...), @import "..."
+	s.readCSS(Edge{From: obj}, body)
+
+	s.readText(obj, body)
+}
+
+// Найти адреса в тексте: "http://...", "//..."
+func (s *Scanner) readText(obj *Source, body []byte) {
 
 	// Шаблоны для протоколов:
 	// http://...
 	// https://...
 	// //...
-	reg = regexp.MustCompile(`\/\/ *`)
-	res = reg.FindAllIndex(body, -1)
+	reg := regexp.MustCompile(`\/\/ *`)
+	res := reg.FindAllIndex(body, -1)
 	for i := 0; i < len(res); i++ {
 		if url := s.searchLink(body, res[i][1]); url != nil {
-			s.workers.Add(1)
-			go s.scan(url)
+			s.follow(Edge{From: obj, Attr: "text"}, url)
 		}
 	}
 }
 
 func (this *Scanner) searchLink(b []byte, s int) *url.URL {
+	str := readLink(b, s)
+
+	// Пытаемься распарсить в ссылку:
+	u, e := url.Parse(str)
+	if e != nil {
+		this.log.Debug("Не удалось прочитать ссылку в тексте", "value", str, "err", e)
+		return nil
+	}
+
+	// Относительные ссылки в абсолютные, чтоб программа могла
+	// сравнить домен ссылки с родным: (Только внутри программы)
+	if u.IsAbs() == false {
+		u.Scheme = this.url.Scheme
+		u.Host = this.url.Host
+	}
+
+	return u
+}
+
+// Прочитать текст ссылки в позиции s: в кавычках или до разделителя.
+func readLink(b []byte, s int) string {
 	// Когда нибудь я покрою тебя тестами..
 	// Ищем кавычки, если ссылка в них обрамлена:
 	var sep byte
@@ -801,80 +1225,18 @@ func (this *Scanner) searchLink(b []byte, s int) *url.URL {
 		}
 	}
 
-	// Пытаемься распарсить в ссылку:
-	u, e := url.Parse(str)
-	if e != nil {
-		log.Printf("Не удалось прочитать ссылку: %v", e.Error())
-		return nil
-	}
-
-	// Относительные ссылки в абсолютные, чтоб программа могла
-	// сравнить домен ссылки с родным: (Только внутри программы)
-	if u.IsAbs() == false {
-		u.Scheme = this.url.Scheme
-		u.Host = this.url.Host
-	}
-
-	return u
-}
-
-// Получить каталог исполняемого файла
-func (s *Scanner) binPath() (string, error) {
-	path, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("Не удалось получить расположение исполняемого файла: %w", err)
-	}
-	return filepath.Dir(path), nil
+	return str
 }
 
-// Проверка ссылки на интересующий нас протокол
+// Проверка ссылки на интересующий нас протокол:
+// протокол, для которого есть загрузчик. См.: ScannerParams.Fetchers
 func (s *Scanner) IsInterstingProtocol(url *url.URL) bool {
 
 	// Список всех протоколов:
 	// http://www.iana.org/assignments/uri-schemes/uri-schemes.xhtml
 
-	switch url.Scheme {
-	case "http", "https", "file":
-		return true
-	}
-
-	return false
-}
-
-// Разбор пользовательского URL
-func (s *Scanner) parseURL(text string) (*url.URL, error) {
-	text = strings.ToLower(text)
-	if len(text) < 5 {
-		return nil, fmt.Errorf("Слишком короткий адрес сайта")
-	}
-
-	// HTTPS:
-	if strings.Index(text, "https://") == 0 {
-		return url.Parse(text)
-	}
-
-	// HTTP:
-	if strings.Index(text, "http://") == 0 {
-		return url.Parse(text)
-	}
-
-	// Относительный:
-	if strings.Index(text, "//") == 0 {
-		return url.Parse(text)
-	}
-
-	// Парсим:
-	url, err := url.Parse("http://" + text)
-	if err != nil {
-		return nil, err
-	}
-
-	// Пустой URL:
-	if url.Host == "" {
-		return nil, fmt.Errorf("Не удалось однозначно прочитать URL")
-	}
-
-	return url, nil
+	_, ok := s.fetchers[url.Scheme]
+	return ok
 }
 
 // Статус сканирования
@@ -903,11 +1265,11 @@ func (s *Scanner) Params() ScannerParams {
 // детальную информацию о причине сбоя для вывода пользователю.
 //
 // Примечания:
-//   * По умолчанию равно nil;
-//   * Всегда содержит последнюю возникшую ошибку, кроме ошибки
+//   - По умолчанию равно nil;
+//   - Всегда содержит последнюю возникшую ошибку, кроме ошибки
 //     попытки запуска уже запущенного сканера. Эта ошибка
 //     только возвращается методом Start();
-//   * При повторном запуске сканнера сбрасывается на nil
+//   - При повторном запуске сканнера сбрасывается на nil
 //     (Корректный запуск без ошибок);
 func (s *Scanner) Err() error {
 	s.mu.RLock()
@@ -915,7 +1277,28 @@ func (s *Scanner) Err() error {
 	return s.err
 }
 
+// Получить путь к файлу манифеста загруженных ресурсов.
+// Пустая строка, если манифест не пишется: режим проверки ссылок.
+// Инициализируется каждый раз при вызова метода: Scanner.Start()
+func (s *Scanner) ManifestFile() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.manifest == nil {
+		return ""
+	}
+	return s.manifest.file.Name()
+}
+
+// Получить путь к файлу журнала.
+// Инициализируется каждый раз при вызова метода: Scanner.Start()
+func (s *Scanner) LogFile() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.logFile
+}
+
 // Получить путь к корневой папке для данных сайта.
+// Для архивного вывода - путь к файлу архива. См.: ScannerParams.OutputFormat
 // Инициализируется каждый раз при вызова метода: Scanner.Start()
 func (s *Scanner) Dir() string {
 	s.mu.RLock()
@@ -958,18 +1341,9 @@ func (s *Scanner) Report(full bool) string {
 		cell("Статус", len3) + sep +
 		"\n" + line(50) + "\n"
 
-	var totalCount, totalCountExt, totalSize int64
 	a := s.sources.List()
 	for _, obj := range a {
-		totalCount++
-
 		obj.mu.RLock()
-		if obj.isExternal {
-			totalCountExt++
-		} else {
-			totalSize += obj.size
-		}
-
 		if !full && !(obj.state == SourceDownload || obj.state == SourceRead || obj.state == SourceRequest || obj.state == SourceSave) {
 			obj.mu.RUnlock()
 			continue
@@ -986,16 +1360,15 @@ func (s *Scanner) Report(full bool) string {
 			"\n"
 	}
 
-	s.mu.RLock()
-	var threads = s.threads
-	s.mu.RUnlock()
-
+	sum := s.Summary()
 	return r + line(50) + "\n" +
-		"\nКол-во горутин:           " + fmt.Sprint(threads) +
-		"\nКол-во всех ссылок:       " + fmt.Sprint(totalCount) +
-		"\nКол-во внешних ссылок:    " + fmt.Sprint(totalCountExt) +
-		"\nКол-во внутренних ссылок: " + fmt.Sprint(totalCount-totalCountExt) +
-		"\nОбъём данных:             " + s.repSize(float64(totalSize)) +
+		"\nКол-во горутин:           " + fmt.Sprint(sum.Threads) +
+		"\nКол-во всех ссылок:       " + fmt.Sprint(sum.Count) +
+		"\nКол-во внешних ссылок:    " + fmt.Sprint(sum.CountExternal) +
+		"\nКол-во внутренних ссылок: " + fmt.Sprint(sum.CountInternal) +
+		"\nОбъём данных:             " + s.repSize(float64(sum.Size)) +
+		"\nПередано по сети:         " + s.repSize(float64(sum.SizeWire)) +
+		"\nДедупликация:             " + s.repSize(float64(sum.DedupSize)) + " сэкономлено, файлов: " + fmt.Sprint(sum.DedupCount) +
 		"\nВремя работы:             " + s.repDuration(time.Since(s.DateStart()))
 }
 
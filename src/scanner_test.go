@@ -0,0 +1,93 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Запустить сканирование и дождаться его завершения.
+func runScanner(t *testing.T, params ScannerParams) *Scanner {
+	t.Helper()
+	if params.OutputDir == "" {
+		params.OutputDir = t.TempDir()
+	}
+	s := NewScanner()
+	if err := s.Start(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		st := s.State()
+		if st != ScannerPreparing && st != ScannerScanning {
+			return s
+		}
+		if time.Now().After(deadline) {
+			s.Stop()
+			t.Fatalf("сканирование не завершилось, состояние: %v", st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Найти ресурс сканера по адресу.
+func findSource(t *testing.T, s *Scanner, u string) *Source {
+	t.Helper()
+	for _, v := range s.Sources() {
+		if v.URL().String() == u {
+			return v
+		}
+	}
+	t.Fatalf("ресурс не найден: %v", u)
+	return nil
+}
+
+// Прочитать файл, сохранённый для ресурса.
+func sourceFile(t *testing.T, obj *Source) string {
+	t.Helper()
+	if obj.File() == "" {
+		t.Fatalf("файл не сохранён: %v", obj.URL())
+	}
+	data, err := os.ReadFile(obj.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStartFailureClosesOutput(t *testing.T) {
+	dir := t.TempDir()
+	busy := filepath.Join(dir, "busy")
+	if err := os.WriteFile(busy, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Журнал нельзя создать: путь к папке занят файлом.
+	f := NewMemoryFetcher().Add("http://mem.test/", "text/html", "<!DOCTYPE html>")
+	s := runScanner(t, ScannerParams{
+		URL:          "http://mem.test/",
+		OutputDir:    dir,
+		OutputFormat: OutputZIP,
+		WARC:         true,
+		LogFile:      filepath.Join(busy, "scan.log"),
+		Fetchers:     map[string]Fetcher{"http": f},
+	})
+	if s.State() != ScannerOutputDirError {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+	if s.ctx.Err() == nil {
+		t.Error("контекст сканера не отменён")
+	}
+
+	// Созданные архивы закрыты и читаются:
+	r, err := zip.OpenReader(s.Dir())
+	if err != nil {
+		t.Errorf("архив не завершён: %v", err)
+	} else {
+		r.Close()
+	}
+	readWARC(t, filepath.Join(dir, "mem.test"+WARC_EXT))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Адрес локального сервера по умолчанию.
+// См.: Server
+const DEFAULT_SERVE_ADDR = "127.0.0.1:8080"
+
+// Локальный HTTP сервер для просмотра копии сайта.
+//
+// Сервер отдаёт файлы из папки или ZIP архива сайта. Если рядом
+// лежит манифест, он используется для восстановления исходных адресов
+// (В том числе страниц с параметрами запроса), заголовков Content-Type,
+// перенаправлений и кодов ошибок. Без манифеста файлы отдаются по пути.
+type Server struct {
+	fsys     fs.FS
+	closer   io.Closer
+	manifest *Manifest
+	index    map[string]*ManifestEntry // Записи манифеста по пути и запросу: "/a?b"
+	redirect map[string]string         // Перенаправления: исходный путь -> новый адрес
+}
+
+// Создать сервер для папки или ZIP архива сайта.
+//
+// Если путь к манифесту не указан, используется манифест,
+// записанный сканером рядом с данными сайта: <host>.manifest.jsonl
+// Отсутствие манифеста по умолчанию не является ошибкой.
+func NewServer(dir string, manifest string) (*Server, error) {
+	s := &Server{
+		index:    make(map[string]*ManifestEntry),
+		redirect: make(map[string]string),
+	}
+
+	// Файлы сайта:
+	fsys, closer, err := openSite(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.fsys = fsys
+	s.closer = closer
+
+	// Манифест:
+	if manifest == "" {
+		manifest = siteManifest(dir)
+		if _, err := os.Stat(manifest); os.IsNotExist(err) {
+			return s, nil
+		}
+	}
+	s.manifest, err = LoadManifest(manifest)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	for i := range s.manifest.Entries {
+		e := &s.manifest.Entries[i]
+		if e.External {
+			// Внешние ссылки, проверенные сканером, - не часть сайта:
+			// их пути совпадают с путями сайта на другом хосте.
+			continue
+		}
+		u, err := url.Parse(e.URL)
+		if err != nil || normalizeURL(u) != nil {
+			continue
+		}
+		key := u.RequestURI()
+		s.index[key] = e
+
+		// Перенаправление на другой адрес:
+		if e.FinalURL == "" {
+			continue
+		}
+		f, err := url.Parse(e.FinalURL)
+		if err != nil || f.RequestURI() == key {
+			continue
+		}
+		if f.Host == u.Host {
+			s.redirect[key] = f.RequestURI()
+			if _, ok := s.index[f.RequestURI()]; !ok {
+				s.index[f.RequestURI()] = e
+			}
+		} else {
+			s.redirect[key] = f.String()
+		}
+	}
+
+	return s, nil
+}
+
+// Открыть папку или ZIP архив сайта для чтения.
+// Для архива возвращает его для закрытия, иначе nil.
+func openSite(dir string) (fs.FS, io.Closer, error) {
+	file, err := os.Stat(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Не найдены данные сайта: %w", err)
+	}
+	if file.IsDir() {
+		return os.DirFS(dir), nil, nil
+	}
+	if !strings.HasSuffix(strings.ToLower(dir), OutputZIP.Ext()) {
+		return nil, nil, fmt.Errorf("Неподдерживаемый формат данных сайта, ожидается папка или ZIP архив: \"%v\"", dir)
+	}
+
+	z, err := zip.OpenReader(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Не удалось открыть архив с данными сайта: \"%v\": %w", dir, err)
+	}
+	return z, z, nil
+}
+
+// Получить путь к манифесту, записанному сканером рядом
+// с папкой или архивом сайта: <host>.manifest.jsonl
+func siteManifest(dir string) string {
+	dir = filepath.Clean(dir)
+	if strings.HasSuffix(strings.ToLower(dir), OutputZIP.Ext()) {
+		dir = dir[:len(dir)-len(OutputZIP.Ext())]
+	}
+	return dir + MANIFEST_EXT
+}
+
+// Манифест сайта.
+// Равно nil, если сервер работает без манифеста.
+func (s *Server) Manifest() *Manifest {
+	return s.manifest
+}
+
+// Освободить ресурсы сервера: закрыть архив.
+func (s *Server) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// Обработать запрос к копии сайта.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := *r.URL
+	normalizeURL(&u)
+	key := u.RequestURI()
+	if loc, ok := s.redirect[key]; ok {
+		http.Redirect(w, r, loc, http.StatusFound)
+		return
+	}
+
+	// Ресурс из манифеста:
+	if e, ok := s.index[key]; ok {
+		if e.Path == "" {
+			code := e.Status
+			if code < 400 {
+				code = http.StatusNotFound
+			}
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		if e.ContentType != "" {
+			w.Header().Set("Content-Type", e.ContentType)
+		}
+		s.serveFile(w, r, e.Path, e.FetchTime)
+		return
+	}
+
+	// Файл по пути: (Имя по любой из политик имён файлов)
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	for _, p := range []FileNamePolicy{FileNamesUTF8, FileNamesEscape} {
+		if v := fileSegments(name, p); fs.ValidPath(v) {
+			if _, err := fs.Stat(s.fsys, v); err == nil {
+				name = v
+				break
+			}
+		}
+	}
+	s.serveFile(w, r, name, time.Time{})
+}
+
+// Отдать файл сайта.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, name string, modtime time.Time) {
+	if !fs.ValidPath(name) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	data, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if modtime.IsZero() {
+		if info, err := fs.Stat(s.fsys, name); err == nil {
+			modtime = info.ModTime()
+		}
+	}
+
+	http.ServeContent(w, r, name, modtime, bytes.NewReader(data))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Записать копию сайта и манифест для сервера.
+func writeSite(t *testing.T, files map[string]string, entries []ManifestEntry) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "site.test")
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0777)
+		if err := os.WriteFile(p, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		line, _ := json.Marshal(e)
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(siteManifest(dir), []byte(b.String()), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// Выполнить запрос к серверу копии сайта.
+func serveGet(t *testing.T, srv *Server, target string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	return w.Code, string(body)
+}
+
+func TestServeSkipsExternalEntries(t *testing.T) {
+	dir := writeSite(t,
+		map[string]string{"index.html": "home", "lib.js": "lib"},
+		[]ManifestEntry{
+			{URL: "http://site.test/", Path: "index.html", Status: 200, ContentType: "text/html"},
+			{URL: "http://site.test/lib.js", Path: "lib.js", Status: 200, ContentType: "text/javascript"},
+			{URL: "https://cdn.example/", Status: 404, External: true},
+			{URL: "https://cdn.example/lib.js", Status: 200, External: true, FinalURL: "https://cdn.example/v2/lib.js"},
+		})
+	srv, err := NewServer(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	for target, want := range map[string]string{"/": "home", "/lib.js": "lib"} {
+		if code, body := serveGet(t, srv, target); code != 200 || body != want {
+			t.Errorf("%v: %v %q, want 200 %q", target, code, body, want)
+		}
+	}
+	if code, _ := serveGet(t, srv, "/v2/lib.js"); code != 404 {
+		t.Errorf("/v2/lib.js: %v, want 404", code)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Комментарий со ссылкой на карту исходного кода в CSS:
+// /*# sourceMappingURL=style.css.map */
+var cssSourceMap = regexp.MustCompile(`/\*\s*[#@]\s*sourceMappingURL=([^\s*]+)\s*\*/`)
+
+// Проверить, является ли ссылка картой исходного кода:
+// найдена в комментарии sourceMappingURL, заголовке SourceMap
+// или имеет расширение .map
+func isSourceMap(e Edge, u *url.URL) bool {
+	switch e.Attr {
+	case "sourceMappingURL", "SourceMap":
+		return true
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".map")
+}
+
+// Найти ссылку на карту исходного кода в заголовках ответа:
+// SourceMap или устаревший X-SourceMap
+func (s *Scanner) readSourceMapHeader(obj *Source) {
+	obj.mu.RLock()
+	v := obj.header.Get("SourceMap")
+	if v == "" {
+		v = obj.header.Get("X-SourceMap")
+	}
+	obj.mu.RUnlock()
+
+	if v != "" {
+		s.followRef(Edge{From: obj, Attr: "SourceMap"}, v)
+	}
+}
+
+// Карта исходного кода.
+// См.: https://tc39.es/source-map/
+type sourceMap struct {
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+	Sections       []struct {
+		URL string     `json:"url"`
+		Map *sourceMap `json:"map"`
+	} `json:"sections"`
+}
+
+// Найти ссылки на исходные файлы в карте исходного кода.
+//
+// Исходники, содержимое которых встроено в карту (sourcesContent),
+// не запрашиваются: карта самодостаточна. Остальные исходники
+// разрешаются от sourceRoot и адреса карты. Адреса сборщиков вида
+// webpack:// пропускаются сканером как неинтересные протоколы.
+func (s *Scanner) readSourceMap(e Edge, body []byte) {
+	var m sourceMap
+	if err := json.Unmarshal(body, &m); err != nil {
+		s.log.Debug("Ошибка разбора карты исходного кода", "err", err)
+		return
+	}
+	s.readSourceMapSources(e, &m)
+}
+
+// Найти ссылки на исходные файлы в карте или её разделах.
+func (s *Scanner) readSourceMapSources(e Edge, m *sourceMap) {
+	e.Attr = "sources"
+	for i, v := range m.Sources {
+		if i < len(m.SourcesContent) && m.SourcesContent[i] != nil {
+			continue
+		}
+		if m.SourceRoot != "" && !strings.Contains(v, ":") && !strings.HasPrefix(v, "/") {
+			v = strings.TrimSuffix(m.SourceRoot, "/") + "/" + v
+		}
+		s.followRef(e, v)
+	}
+
+	// Составная карта:
+	for _, v := range m.Sections {
+		if v.Map != nil {
+			s.readSourceMapSources(e, v.Map)
+		} else if v.URL != "" {
+			s.followRef(Edge{From: e.From, Attr: "sections"}, v.URL)
+		}
+	}
+}
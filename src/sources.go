@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
 	"net/url"
 	"sync"
+	"time"
 )
 
 // Статус ресурса.
@@ -33,11 +36,50 @@ func (v SourceState) String() string {
 		return "Сохранён"
 	case SourceSkip:
 		return "Пропуск"
+	case SourceChecked:
+		return "Проверен"
+	case SourceCanceled:
+		return "Отменён"
 	default:
 		return "Unknown"
 	}
 }
 
+// Получить машиночитаемый код статуса ресурса.
+// Используется в отчётах для внешних программ.
+func (v SourceState) Code() string {
+	switch v {
+	case SourceWait:
+		return "wait"
+	case SourceRequest:
+		return "request"
+	case SourceRequestWaitRepeat:
+		return "request_wait_repeat"
+	case SourceRequestError:
+		return "request_error"
+	case SourceDownload:
+		return "download"
+	case SourceDownloadError:
+		return "download_error"
+	case SourceRead:
+		return "read"
+	case SourceSave:
+		return "save"
+	case SourceSaveError:
+		return "save_error"
+	case SourceComplete:
+		return "complete"
+	case SourceSkip:
+		return "skip"
+	case SourceChecked:
+		return "checked"
+	case SourceCanceled:
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
+
 const (
 
 	// Ожидание очереди на скачивание ресурса
@@ -72,6 +114,14 @@ const (
 
 	// Пропуск ресурса
 	SourceSkip
+
+	// Ресурс доступен и проверен без сохранения.
+	// Используется в режиме проверки ссылок. См.: ScannerParams.LinkCheck
+	SourceChecked
+
+	// Обработка ресурса отменена из-за остановки сканера.
+	// См.: Scanner.Stop()
+	SourceCanceled
 )
 
 // Ресурс на сайте
@@ -80,12 +130,31 @@ type Source struct {
 	url           *url.URL    // URL Для запроса ресурса
 	state         SourceState // Текущий статус обработки ресурса
 	mime          string      // Mime тип ресурса: http.DetectContentType()
-	size          int64       // Размер в байтах
+	charset       string      // Кодировка текста: "utf-8", "windows-1251", ... Пустая для нетекстовых ресурсов
+	status        int         // HTTP код последнего ответа сервера. 0 - ответа не было
+	size          int64       // Размер в байтах после распаковки
+	sizeWire      int64       // Размер в байтах, переданных по сети (До распаковки)
 	isExternal    bool        // Флаг внешнего ресурса. Внешние ресурсы не запрашиваются и только для статистики
 	isInteresting bool        // Флаг интересного ресурса. См.: Scanner.IsInterstingProtocol()
 	err           error       // Ошибка основной обработки ресурса
 	errRead       error       // Ошибка анализа ресурса (Второстепенная, не блокирующая)
 	repeats       int         // Счётчик повторных попыток запроса из-за ошибок
+	referrer      *url.URL    // Адрес ресурса, в котором впервые найдена ссылка. Может быть nil
+	list          *Sources    // Список, которому принадлежит ресурс
+	in            []*Edge     // Входящие ссылки на ресурс. Под блокировкой списка: Sources.mu
+	out           []*Edge     // Исходящие ссылки из ресурса. Под блокировкой списка: Sources.mu
+	depth         int         // Глубина ресурса от исходного URL. Под блокировкой списка: Sources.mu
+	file          string      // Путь к сохранённому файлу на диске
+	path          string      // Путь к файлу внутри папки сайта с разделителем "/"
+	renamed       bool        // Имя файла отличается от имени в URL: "index.html", подбор расширения
+	finalURL      *url.URL    // Адрес после перенаправлений. nil, если перенаправлений не было
+	header        http.Header // Заголовки последнего ответа сервера
+	hash          string      // Хеш тела ресурса: SHA-256 в hex
+	dupOf         *Source     // Первый ресурс с таким же содержимым. См.: ScannerParams.Dedup
+	links         []string    // Ссылки, найденные при чтении ресурса. Только для метаданных WARC
+	dateAdd       time.Time   // Дата обнаружения ссылки
+	dateStart     time.Time   // Дата начала запроса ресурса
+	dateFinish    time.Time   // Дата завершения обработки ресурса
 }
 
 // URL Адрес ресурса.
@@ -130,7 +199,24 @@ func (s *Source) Mime() string {
 	return s.mime
 }
 
-// Размер в байтах.
+// Кодировка текстового ресурса: "utf-8", "windows-1251", ...
+// Становится доступно только после скачивания ресурса.
+// Пустая строка для нетекстовых ресурсов.
+func (s *Source) Charset() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.charset
+}
+
+// HTTP код последнего ответа сервера.
+// Равно 0, если ответ ещё не получен или не было запроса.
+func (s *Source) Status() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Размер в байтах после распаковки.
 // Становится доступно только после скачивания
 // ресурса и не для внешних ресурсов.
 func (s *Source) Size() int64 {
@@ -139,6 +225,16 @@ func (s *Source) Size() int64 {
 	return s.size
 }
 
+// Размер в байтах, фактически переданных по сети.
+// Для сжатых ответов (gzip, deflate, br, zstd) меньше Size().
+// Становится доступно только после скачивания
+// ресурса и не для внешних ресурсов.
+func (s *Source) SizeWire() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sizeWire
+}
+
 // Ошибка обработки ресурса.
 // Используется как дополнение для состояний ресурса,
 // указывающих на ошибку обработки.
@@ -157,6 +253,96 @@ func (s *Source) ErrRead() error {
 	return s.errRead
 }
 
+// Кол-во повторных попыток запроса из-за ошибок.
+func (s *Source) Repeats() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.repeats
+}
+
+// Адрес ресурса, в котором впервые была найдена ссылка.
+// Равно nil для исходного URL и корневых файлов: robots.txt, ...
+func (s *Source) Referrer() *url.URL {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.referrer
+}
+
+// Список всех мест, в которых найдена ссылка на ресурс.
+// Возвращает копию, безопасную для внесения изменений.
+func (s *Source) Refs() []SourceRef {
+	s.list.mu.RLock()
+	defer s.list.mu.RUnlock()
+
+	a2 := make([]SourceRef, len(s.in))
+	for i, e := range s.in {
+		a2[i] = SourceRef{From: e.From.url, Tag: e.Tag, Attr: e.Attr}
+	}
+
+	return a2
+}
+
+// Путь к сохранённому файлу на диске.
+// Становится доступно только после успешного сохранения ресурса.
+func (s *Source) File() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.file
+}
+
+// Адрес ресурса после перенаправлений.
+// Равно nil, если сервер не перенаправлял запрос.
+func (s *Source) FinalURL() *url.URL {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.finalURL
+}
+
+// Заголовки последнего ответа сервера.
+// Возвращает копию, безопасную для внесения изменений.
+func (s *Source) Header() http.Header {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.header.Clone()
+}
+
+// Хеш тела ресурса: SHA-256 в hex.
+// Становится доступно только после скачивания
+// ресурса и не для внешних ресурсов.
+func (s *Source) Hash() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hash
+}
+
+// Первый ресурс с таким же содержимым.
+// Равно nil, если ресурс не является дубликатом или
+// дедупликация выключена. См.: ScannerParams.Dedup
+func (s *Source) DuplicateOf() *Source {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dupOf
+}
+
+// Место, в котором найдена ссылка на ресурс
+type SourceRef struct {
+	From *url.URL // Адрес ресурса (страницы), содержащего ссылку
+	Tag  string   // Тег со ссылкой: "a", "img", ... Пустой для текстовых ресурсов
+	Attr string   // Атрибут тега со ссылкой: "href", "src", ... или способ поиска: "url()", "text"
+}
+
+// Получить текстовое представление места ссылки.
+func (v SourceRef) String() string {
+	var from string
+	if v.From != nil {
+		from = v.From.String()
+	}
+	if v.Tag == "" {
+		return fmt.Sprintf("%v (%v)", from, v.Attr)
+	}
+	return fmt.Sprintf("%v <%v %v>", from, v.Tag, v.Attr)
+}
+
 // Список ресурсов
 type Sources struct {
 	mu sync.RWMutex
@@ -180,8 +366,10 @@ func newSources(parent *Scanner) *Sources {
 //   * Если в списке нет ресурса с таким URL, то создаёт
 //     и возвращает новый ресурс.
 //
+// Ссылка e добавляется в граф ссылок в обоих случаях, если
+// она не пустая (e.From != nil). Поле e.To заполняется методом.
 // Метод всегда возвращает экземпляр, который не может быть nil.
-func (s *Sources) Add(url *url.URL) (*Source, bool) {
+func (s *Sources) Add(url *url.URL, e Edge) (*Source, bool) {
 	key := url.String()
 
 	s.mu.Lock()
@@ -190,14 +378,28 @@ func (s *Sources) Add(url *url.URL) (*Source, bool) {
 	// Поиск:
 	v, ok := s.m[key]
 	if ok {
+		if e.From != nil {
+			e.To = v
+			s.link(&e)
+		} else {
+			s.relax(v, 0)
+		}
 		return v, false
 	}
 
 	// Создаём новый:
 	obj := &Source{
 		url:           url,
-		isExternal:    s.p.url.Hostname() != url.Hostname(),
+		isExternal:    s.p.isExternalURL(url),
 		isInteresting: s.p.IsInterstingProtocol(url),
+		dateAdd:       time.Now(),
+		list:          s,
+	}
+	if e.From != nil {
+		obj.referrer = e.From.url
+		obj.depth = -1
+		e.To = obj
+		s.link(&e)
 	}
 	s.a = append(s.a, obj)
 	s.m[key] = obj
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Кандидат изображения из атрибута srcset или imagesrcset.
+//
+// Позиция ссылки указывает на её точное место в значении атрибута:
+// value[Start:End] == URL. Это позволяет заменить ссылку, не трогая
+// дескрипторы и разделители.
+type SrcsetCandidate struct {
+	URL        string // Ссылка, как записана в атрибуте
+	Descriptor string // Дескрипторы: "2x", "100w", "100w 50h" или пусто
+	Start      int    // Начало ссылки в значении атрибута, байт
+	End        int    // Конец ссылки в значении атрибута, байт
+}
+
+// Размер изображения из атрибута sizes.
+type SourceSize struct {
+	Media string // Медиа условие: "(max-width: 600px)" или пусто
+	Size  string // Длина: "100vw", "calc(100vw - 10px)", "auto"
+}
+
+// Пробельные символы ASCII по спецификации HTML
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// Число с плавающей точкой по спецификации HTML: "1", "1.5", ".5", "1e2"
+var srcsetFloat = regexp.MustCompile(`^-?(\d+(\.\d+)?|\.\d+)([eE][-+]?\d+)?$`)
+
+// Разобрать значение атрибута srcset или imagesrcset.
+//
+// Разбор выполняется по алгоритму спецификации HTML: ссылки могут
+// содержать запятые, дескрипторы - скобки. Кандидаты с некорректными
+// дескрипторами пропускаются, как это делает браузер.
+// См.: https://html.spec.whatwg.org/multipage/images.html#parsing-a-srcset-attribute
+func ParseSrcset(value string) []SrcsetCandidate {
+	res := make([]SrcsetCandidate, 0)
+	pos := 0
+	for {
+		// Разделители между кандидатами:
+		for pos < len(value) && (isSpace(value[pos]) || value[pos] == ',') {
+			pos++
+		}
+		if pos >= len(value) {
+			return res
+		}
+
+		// Ссылка - до пробела:
+		start := pos
+		for pos < len(value) && !isSpace(value[pos]) {
+			pos++
+		}
+		end := pos
+		var descs []string
+		if value[end-1] == ',' {
+			// Кандидат без дескрипторов:
+			for end > start && value[end-1] == ',' {
+				end--
+			}
+		} else {
+			descs, pos = srcsetDescriptors(value, pos)
+		}
+		if end == start {
+			continue
+		}
+
+		if srcsetValid(descs) {
+			res = append(res, SrcsetCandidate{
+				URL:        value[start:end],
+				Descriptor: strings.Join(descs, " "),
+				Start:      start,
+				End:        end,
+			})
+		}
+	}
+}
+
+// Прочитать дескрипторы кандидата до запятой вне скобок.
+// Возвращает дескрипторы и позицию после них.
+func srcsetDescriptors(value string, pos int) ([]string, int) {
+	const (
+		inDescriptor = iota
+		inParens
+		afterDescriptor
+	)
+
+	var descs []string
+	var cur strings.Builder
+	state := inDescriptor
+	for pos < len(value) && isSpace(value[pos]) {
+		pos++
+	}
+	for ; pos < len(value); pos++ {
+		c := value[pos]
+		switch state {
+		case inDescriptor:
+			switch {
+			case isSpace(c):
+				if cur.Len() > 0 {
+					descs = append(descs, cur.String())
+					cur.Reset()
+					state = afterDescriptor
+				}
+			case c == ',':
+				if cur.Len() > 0 {
+					descs = append(descs, cur.String())
+				}
+				return descs, pos + 1
+			case c == '(':
+				cur.WriteByte(c)
+				state = inParens
+			default:
+				cur.WriteByte(c)
+			}
+		case inParens:
+			cur.WriteByte(c)
+			if c == ')' {
+				state = inDescriptor
+			}
+		case afterDescriptor:
+			if !isSpace(c) {
+				state = inDescriptor
+				pos--
+			}
+		}
+	}
+	if cur.Len() > 0 {
+		descs = append(descs, cur.String())
+	}
+
+	return descs, pos
+}
+
+// Проверить дескрипторы кандидата: не больше одного
+// дескриптора ширины, плотности и высоты, высота только
+// вместе с шириной, значения положительные.
+func srcsetValid(descs []string) bool {
+	var w, x, h bool
+	for _, d := range descs {
+		v := d[:len(d)-1]
+		switch d[len(d)-1] {
+		case 'w':
+			if w || x {
+				return false
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || strings.ContainsAny(v, "+-") {
+				return false
+			}
+			w = true
+		case 'x':
+			if w || x || h || !srcsetFloat.MatchString(v) {
+				return false
+			}
+			if n, err := strconv.ParseFloat(v, 64); err != nil || n < 0 {
+				return false
+			}
+			x = true
+		case 'h':
+			if h || x {
+				return false
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || strings.ContainsAny(v, "+-") {
+				return false
+			}
+			h = true
+		default:
+			return false
+		}
+	}
+
+	return !h || w
+}
+
+// Разобрать значение атрибута sizes.
+//
+// Значение - список через запятую, каждый элемент которого -
+// необязательное медиа условие и длина. Запятые внутри скобок
+// не разделяют элементы. Пустые элементы пропускаются.
+// См.: https://html.spec.whatwg.org/multipage/images.html#parsing-a-sizes-attribute
+func ParseSizes(value string) []SourceSize {
+	res := make([]SourceSize, 0)
+	add := func(v string) {
+		v = strings.TrimFunc(v, func(r rune) bool { return r < 0x80 && isSpace(byte(r)) })
+		if v == "" {
+			return
+		}
+
+		// Длина - последний элемент, возможно со скобками:
+		depth, i := 0, len(v)
+		for i > 0 {
+			c := v[i-1]
+			if c == ')' {
+				depth++
+			} else if c == '(' {
+				depth--
+			} else if isSpace(c) && depth == 0 {
+				break
+			}
+			i--
+		}
+		size := v[i:]
+		media := strings.TrimRight(v[:i], " \t\n\f\r")
+		if strings.HasPrefix(size, "(") {
+			// Только медиа условие без длины:
+			return
+		}
+		res = append(res, SourceSize{Media: media, Size: size})
+	}
+
+	depth, start := 0, 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				add(value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(value[start:])
+
+	return res
+}
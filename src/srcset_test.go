@@ -0,0 +1,111 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSrcset(t *testing.T) {
+	type c = SrcsetCandidate
+	tests := []struct {
+		name  string
+		value string
+		want  []SrcsetCandidate
+	}{
+		{"пусто", "", []c{}},
+		{"одна ссылка", "a.jpg", []c{{URL: "a.jpg", Descriptor: ""}}},
+		{"плотность", "a.jpg 1x, b.jpg 2x", []c{{URL: "a.jpg", Descriptor: "1x"}, {URL: "b.jpg", Descriptor: "2x"}}},
+		{"ширина и высота", "a.jpg 100w 50h", []c{{URL: "a.jpg", Descriptor: "100w 50h"}}},
+		{"лишние пробелы и запятые", " ,\ta.jpg\n1.5x ,, b.jpg,", []c{{URL: "a.jpg", Descriptor: "1.5x"}, {URL: "b.jpg", Descriptor: ""}}},
+		{"запятая в ссылке", "a,b.jpg 1x", []c{{URL: "a,b.jpg", Descriptor: "1x"}}},
+		{"запятая в конце ссылки", "a.jpg,b.jpg 2x", []c{{URL: "a.jpg,b.jpg", Descriptor: "2x"}}},
+		{"ссылки без дескрипторов", "a.jpg, b.jpg", []c{{URL: "a.jpg", Descriptor: ""}, {URL: "b.jpg", Descriptor: ""}}},
+		{"data URL", "data:image/png;base64,iVBORw0= 1x, b.jpg 2x", []c{{URL: "data:image/png;base64,iVBORw0=", Descriptor: "1x"}, {URL: "b.jpg", Descriptor: "2x"}}},
+		{"скобки в дескрипторе", "a.jpg (x, y), b.jpg 2x", []c{{URL: "b.jpg", Descriptor: "2x"}}},
+		{"два дескриптора плотности", "a.jpg 1x 2x, b.jpg", []c{{URL: "b.jpg", Descriptor: ""}}},
+		{"две ширины", "a.jpg 100w 200w, b.jpg", []c{{URL: "b.jpg", Descriptor: ""}}},
+		{"ширина и плотность", "a.jpg 100w 2x, b.jpg", []c{{URL: "b.jpg", Descriptor: ""}}},
+		{"высота без ширины", "a.jpg 50h, b.jpg", []c{{URL: "b.jpg", Descriptor: ""}}},
+		{"нулевая ширина", "a.jpg 0w, b.jpg", []c{{URL: "b.jpg", Descriptor: ""}}},
+		{"отрицательная плотность", "a.jpg -1x, b.jpg", []c{{URL: "b.jpg", Descriptor: ""}}},
+		{"ширина со знаком", "a.jpg +100w, b.jpg", []c{{URL: "b.jpg", Descriptor: ""}}},
+		{"неизвестный дескриптор", "a.jpg big, b.jpg 1e1x", []c{{URL: "b.jpg", Descriptor: "1e1x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseSrcset(tt.value)
+			for i, v := range got {
+				if tt.value[v.Start:v.End] != v.URL {
+					t.Errorf("[%v]: value[%v:%v] = %q, want %q", i, v.Start, v.End, tt.value[v.Start:v.End], v.URL)
+				}
+				got[i].Start, got[i].End = 0, 0
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSrcset(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSrcsetPositions(t *testing.T) {
+	tests := []struct {
+		value string
+		want  [][2]int
+	}{
+		{"a.jpg", [][2]int{{0, 5}}},
+		{"  a.jpg 1x,\tb.jpg 2x", [][2]int{{2, 7}, {12, 17}}},
+		{"a,b.jpg 1x", [][2]int{{0, 7}}},
+		{"a.jpg,b.jpg 2x", [][2]int{{0, 11}}},
+		{"x.jpg, a,b.jpg 1x", [][2]int{{0, 5}, {7, 14}}},
+		{"a.jpg, b.jpg", [][2]int{{0, 5}, {7, 12}}},
+		{"data:image/png;base64,iVBORw0= 1x, b.jpg 2x", [][2]int{{0, 30}, {35, 40}}},
+	}
+	for _, tt := range tests {
+		got := ParseSrcset(tt.value)
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseSrcset(%q) = %q, want %v candidates", tt.value, got, len(tt.want))
+			continue
+		}
+		for i, v := range got {
+			if v.Start != tt.want[i][0] || v.End != tt.want[i][1] || tt.value[v.Start:v.End] != v.URL {
+				t.Errorf("ParseSrcset(%q)[%v]: %q [%v:%v], want [%v:%v]", tt.value, i, v.URL, v.Start, v.End, tt.want[i][0], tt.want[i][1])
+			}
+		}
+	}
+}
+
+func TestParseSizes(t *testing.T) {
+	type s = SourceSize
+	tests := []struct {
+		name  string
+		value string
+		want  []SourceSize
+	}{
+		{"пусто", "", []s{}},
+		{"только длина", "100vw", []s{{"", "100vw"}}},
+		{"медиа условие", "(max-width: 600px) 480px, 800px", []s{{"(max-width: 600px)", "480px"}, {"", "800px"}}},
+		{"запятая в скобках", "(min-width: 1px) and (max-width: 2px) calc(100vw - 10px), 50vw", []s{{"(min-width: 1px) and (max-width: 2px)", "calc(100vw - 10px)"}, {"", "50vw"}}},
+		{"запятая в функции", "min(100vw, 600px)", []s{{"", "min(100vw, 600px)"}}},
+		{"auto", "auto, 100vw", []s{{"", "auto"}, {"", "100vw"}}},
+		{"лишние пробелы и запятые", " ,\t(min-width: 40em)\n 50vw ,, 100vw,", []s{{"(min-width: 40em)", "50vw"}, {"", "100vw"}}},
+		{"медиа без длины", "(max-width: 600px), 100vw", []s{{"", "100vw"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseSizes(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSizes(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSrcset(t *testing.T) {
+	page := `<img srcset="img/a,1.jpg 1x, data:image/gif;base64,R0lG 2x, /b.jpg 100w">`
+	got := collectLinks(t, "http://site.test/docs/", func(s *Scanner, obj *Source) {
+		s.readHTML(obj, []byte(page))
+	})
+	want := []string{"srcset http://site.test/docs/img/a,1.jpg", "srcset data:image/gif;base64,R0lG", "srcset http://site.test/b.jpg"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Вид ошибки разбора адреса сайта.
+type URLErrorKind int
+
+// Получить текстовое представление вида ошибки.
+func (v URLErrorKind) String() string {
+	switch v {
+	case URLEmpty:
+		return "Не указан адрес сайта"
+	case URLSyntax:
+		return "Некорректный адрес сайта"
+	case URLScheme:
+		return "Неподдерживаемый протокол"
+	case URLHost:
+		return "Некорректное имя хоста"
+	case URLPort:
+		return "Некорректный номер порта"
+	case URLPath:
+		return "Некорректный путь к папке сайта"
+	default:
+		return "Unknown"
+	}
+}
+
+// Получить машиночитаемый код вида ошибки.
+func (v URLErrorKind) Code() string {
+	switch v {
+	case URLEmpty:
+		return "empty"
+	case URLSyntax:
+		return "syntax"
+	case URLScheme:
+		return "scheme"
+	case URLHost:
+		return "host"
+	case URLPort:
+		return "port"
+	case URLPath:
+		return "path"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+
+	// Пустой адрес
+	URLEmpty URLErrorKind = iota
+
+	// Адрес не удалось разобрать
+	URLSyntax
+
+	// Для протокола нет загрузчика, см.: ScannerParams.Fetchers
+	URLScheme
+
+	// Хост не указан или не является корректным доменным именем
+	URLHost
+
+	// Порт вне диапазона 1-65535
+	URLPort
+
+	// Для file:// не указан путь или папка не найдена
+	URLPath
+)
+
+// Ошибка разбора адреса сайта, введённого пользователем.
+// См.: ScannerIncorrectURL, Scanner.Err()
+type URLError struct {
+	Kind URLErrorKind // Вид ошибки
+	URL  string       // Адрес, как его ввёл пользователь
+	Err  error        // Исходная ошибка. Может быть nil
+}
+
+// Получить текст ошибки.
+func (e *URLError) Error() string {
+	msg := e.Kind.String()
+	if e.URL != "" {
+		msg += ": \"" + e.URL + "\""
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Получить исходную ошибку.
+func (e *URLError) Unwrap() error {
+	return e.Err
+}
+
+// Протокол в начале адреса: "http://", "file://", ...
+var urlScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// Разбор адреса сайта, введённого пользователем.
+//
+// Принимаются:
+//   - адреса с протоколом: "https://site.ru/Docs/Guide?ID=ABC";
+//   - хосты без протокола, к ним добавляется "http://": "site.ru",
+//     "localhost:8080", "192.168.1.1/docs", "//site.ru";
+//   - адреса IPv6 в скобках и без: "[::1]:8080", "::1";
+//   - папки на диске: "file:///home/user/site", "C:\site", "/home/user/site";
+//   - адреса других протоколов, для которых есть загрузчик: "ftp://ftp.site.ru/pub/".
+//
+// В нижний регистр переводятся только протокол и хост, путь и запрос
+// сохраняются как есть. Пустой путь заменяется на "/", фрагмент
+// отбрасывается. Хост приводится к виду из normalizeURL().
+// Возвращает ошибку *URLError.
+func (s *Scanner) parseURL(text string) (*url.URL, error) {
+	text = strings.TrimSpace(text)
+	fail := func(kind URLErrorKind, err error) (*url.URL, error) {
+		return nil, &URLError{Kind: kind, URL: text, Err: err}
+	}
+	if text == "" {
+		return fail(URLEmpty, nil)
+	}
+
+	// Адрес без протокола:
+	raw := text
+	switch {
+	case urlScheme.MatchString(raw):
+		i := strings.Index(raw, "://")
+		raw = strings.ToLower(raw[:i]) + raw[i:]
+	case strings.HasPrefix(raw, "//"):
+		raw = "http:" + raw
+	case filepath.IsAbs(raw) || strings.HasPrefix(raw, "/"):
+		raw = (&url.URL{Scheme: "file", Path: "/" + strings.TrimPrefix(filepath.ToSlash(raw), "/")}).String()
+	default:
+		host := raw
+		if i := strings.IndexAny(host, "/?#"); i != -1 {
+			host = host[:i]
+		}
+		if ip := net.ParseIP(host); ip != nil && strings.Contains(host, ":") {
+			raw = "[" + host + "]" + raw[len(host):]
+		}
+		raw = "http://" + raw
+	}
+
+	// Порт проверяется до разбора, чтобы ошибка была понятной:
+	if p := rawPort(raw); p != "" {
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return fail(URLPort, nil)
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fail(URLSyntax, err)
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if _, ok := s.fetchers[u.Scheme]; !ok {
+		return fail(URLScheme, fmt.Errorf("Поддерживаются: %v", strings.Join(s.schemes(), ", ")))
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Host == "localhost" {
+			u.Host = ""
+		}
+		if u.Host != "" {
+			return fail(URLHost, fmt.Errorf("Файлы на других компьютерах не поддерживаются"))
+		}
+		if u.Path == "" {
+			return fail(URLPath, nil)
+		}
+
+		// Папка сайта должна существовать. Путь к ней заканчивается
+		// на "/", чтобы относительные ссылки разрешались внутри неё:
+		info, err := os.Stat(localPath(u.Path))
+		if err != nil {
+			return fail(URLPath, err)
+		}
+		if info.IsDir() && !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+	default:
+		if u.Hostname() == "" {
+			return fail(URLHost, nil)
+		}
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+
+	if err := normalizeURL(u); err != nil {
+		return fail(URLHost, err)
+	}
+	return u, nil
+}
+
+// Получить порт из адреса с протоколом без разбора: "http://site.ru:8080/" -> "8080".
+// Возвращает пустую строку, если порт не указан.
+func rawPort(raw string) string {
+	i := strings.Index(raw, "://")
+	if i == -1 {
+		return ""
+	}
+	host := raw[i+3:]
+	if i := strings.IndexAny(host, "/?#"); i != -1 {
+		host = host[:i]
+	}
+	if i := strings.LastIndexByte(host, '@'); i != -1 {
+		host = host[i+1:]
+	}
+	if i := strings.LastIndexByte(host, ':'); i != -1 && !strings.Contains(host[i:], "]") {
+		return host[i+1:]
+	}
+	return ""
+}
+
+// Преобразование международных доменных имён (IDNA).
+// Символ "_" в именах допускается: он встречается в реальных
+// адресах, хотя запрещён правилами STD3.
+var hostIDNA = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.BidiRule())
+
+// Привести URL к единому виду, чтобы одинаковые адреса не
+// считались разными ресурсами:
+//   - хост переводится в нижний регистр, международные доменные
+//     имена - в punycode: "пример.рф" -> "xn--e1afmkfd.xn--p1ai";
+//   - процентное кодирование пути приводится к одному виду: закодированные
+//     без необходимости символы раскодируются, шестнадцатеричные цифры
+//     пишутся в верхнем регистре: "/%7euser/%d0%ba" -> "/~user/%D0%BA".
+//
+// Изменяет переданный URL. Возвращает ошибку, если хост не
+// является корректным доменным именем.
+func normalizeURL(u *url.URL) error {
+	if u.Host != "" {
+		host, err := asciiHost(u.Host)
+		if err != nil {
+			return err
+		}
+		u.Host = host
+	}
+
+	if u.RawPath != "" {
+		raw := normalizeEscapes(u.RawPath)
+		if p, err := url.PathUnescape(raw); err == nil {
+			u.Path = p
+			u.RawPath = raw
+			if raw == (&url.URL{Path: p}).EscapedPath() {
+				u.RawPath = ""
+			}
+		}
+	}
+
+	return nil
+}
+
+// Перевести хост с необязательным портом в ASCII: "Пример.РФ:8080" ->
+// "xn--e1afmkfd.xn--p1ai:8080". Адреса IPv6 и хосты в ASCII только
+// переводятся в нижний регистр.
+func asciiHost(host string) (string, error) {
+	name, port := host, ""
+	if i := strings.LastIndexByte(host, ':'); i != -1 && !strings.Contains(host[i:], "]") {
+		name, port = host[:i], host[i:]
+	}
+	if strings.HasPrefix(name, "[") || isASCII(name) {
+		return strings.ToLower(host), nil
+	}
+
+	v, err := hostIDNA.ToASCII(name)
+	if err != nil {
+		return "", fmt.Errorf("Некорректное доменное имя: \"%v\": %w", name, err)
+	}
+	return v + port, nil
+}
+
+// Получить хост с необязательным портом для показа пользователю:
+// "xn--e1afmkfd.xn--p1ai" -> "пример.рф". Если хост не является
+// международным доменным именем, возвращает его без изменений.
+func unicodeHost(host string) string {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		name, port = host, ""
+	}
+	if !strings.Contains(name, "xn--") {
+		return host
+	}
+
+	v, err := hostIDNA.ToUnicode(name)
+	if err != nil {
+		return host
+	}
+	if port != "" {
+		return v + ":" + port
+	}
+	return v
+}
+
+// Проверить, состоит ли строка только из символов ASCII.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// Привести процентное кодирование к одному виду по RFC 3986:
+// незарезервированные символы (буквы, цифры, "-._~") раскодируются,
+// остальные коды пишутся в верхнем регистре.
+func normalizeEscapes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(strings.ToUpper(s[i : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// Незарезервированный символ URL: не требует кодирования.
+// См.: https://www.rfc-editor.org/rfc/rfc3986#section-2.3
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
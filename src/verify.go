@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Вид проблемы, найденной при проверке копии сайта.
+type VerifyKind int
+
+// Получить текстовое представление вида проблемы.
+func (v VerifyKind) String() string {
+	switch v {
+	case VerifyMissing:
+		return "Файл отсутствует"
+	case VerifyLive:
+		return "Ссылка на живой сайт"
+	case VerifyEmpty:
+		return "Пустой файл"
+	case VerifyHash:
+		return "Хеш не совпадает с манифестом"
+	default:
+		return "Unknown"
+	}
+}
+
+// Получить машиночитаемый код вида проблемы.
+func (v VerifyKind) Code() string {
+	switch v {
+	case VerifyMissing:
+		return "missing"
+	case VerifyLive:
+		return "live"
+	case VerifyEmpty:
+		return "empty"
+	case VerifyHash:
+		return "hash"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+
+	// Ссылка или запись манифеста указывает на файл, которого нет
+	VerifyMissing VerifyKind = iota
+
+	// Абсолютная ссылка на исходный сайт: при просмотре
+	// копии такой ресурс загружается из интернета
+	VerifyLive
+
+	// Файл нулевого размера
+	VerifyEmpty
+
+	// Содержимое файла изменилось после сканирования
+	VerifyHash
+)
+
+// Проблема, найденная при проверке копии сайта.
+type VerifyIssue struct {
+	Kind VerifyKind `json:"-"`
+	Code string     `json:"kind"`
+	File string     `json:"file"`          // Файл, в котором найдена проблема
+	URL  string     `json:"url,omitempty"` // Ссылка или адрес ресурса
+}
+
+// Результат проверки копии сайта.
+type VerifyReport struct {
+	Site   string        `json:"site"`
+	Files  int           `json:"files"`
+	Links  int           `json:"links"`
+	Issues []VerifyIssue `json:"issues"`
+}
+
+// Получить текстовый отчёт о проверке.
+func (r *VerifyReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Сайт: %v\nФайлов: %v\nЛокальных ссылок: %v\nПроблем: %v\n%v\n", r.Site, r.Files, r.Links, len(r.Issues), line(50))
+	for _, v := range r.Issues {
+		if v.URL == "" {
+			fmt.Fprintf(&b, "%v: %v\n", v.Kind, v.File)
+		} else {
+			fmt.Fprintf(&b, "%v: %v -> %v\n", v.Kind, v.File, v.URL)
+		}
+	}
+	return b.String()
+}
+
+// Адрес-заглушка для разрешения относительных ссылок при проверке.
+// Ссылки на этот адрес - локальные, на адрес сайта - на живой сайт.
+var verifyBase = &url.URL{Scheme: "gomirror", Host: "local"}
+
+// Проверить копию сайта на полноту.
+//
+// Сохранённые HTML и CSS файлы разбираются теми же методами, что и
+// при сканировании: Scanner.readHTML(), Scanner.readCSS(). Каждая
+// локальная ссылка разрешается в файл копии. Найденные в тексте адреса
+// без атрибута или url() не проверяются - они неоднозначны.
+//
+// Адрес сайта site нужен для поиска ссылок на живой сайт. Если он не
+// указан, берётся из манифеста. Манифест ищется рядом с данными сайта,
+// если путь к нему не указан; без манифеста не проверяются хеши.
+func Verify(dir string, manifest string, site string) (*VerifyReport, error) {
+	fsys, closer, err := openSite(dir)
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+
+	// Манифест:
+	var m *Manifest
+	if manifest == "" {
+		manifest = siteManifest(dir)
+		if _, err := os.Stat(manifest); os.IsNotExist(err) {
+			manifest = ""
+		}
+	}
+	if manifest != "" {
+		if m, err = LoadManifest(manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	// Адрес сайта:
+	if site == "" && m != nil && len(m.Entries) > 0 {
+		site = m.Entries[0].URL
+	}
+	if site == "" {
+		return nil, fmt.Errorf("Не удалось определить адрес сайта: нет манифеста, укажите адрес")
+	}
+	origin, err := url.Parse(site)
+	if err != nil || origin.Host == "" && origin.Scheme != "file" {
+		return nil, fmt.Errorf("Некорректный адрес сайта: \"%v\"", site)
+	}
+	if origin.Scheme == "file" {
+		// Сайт на диске: корень - папка первого ресурса
+		origin = &url.URL{Scheme: origin.Scheme, Path: path.Dir(origin.Path+"x") + "/"}
+	} else {
+		origin = &url.URL{Scheme: origin.Scheme, Host: origin.Host, Path: "/"}
+	}
+
+	r := &VerifyReport{Site: origin.String(), Issues: make([]VerifyIssue, 0)}
+	seen := make(map[string]bool)
+	issue := func(kind VerifyKind, file string, link string) {
+		key := kind.Code() + " " + file + " " + link
+		if !seen[key] {
+			seen[key] = true
+			r.Issues = append(r.Issues, VerifyIssue{Kind: kind, Code: kind.Code(), File: file, URL: link})
+		}
+	}
+
+	// Файлы по записям манифеста:
+	pages := make(map[string]*url.URL)
+	hashes := make(map[string]string)
+	if m != nil {
+		for i := range m.Entries {
+			e := &m.Entries[i]
+			if e.Path == "" {
+				continue
+			}
+			if u, err := url.Parse(e.URL); err == nil && pages[e.Path] == nil {
+				pages[e.Path] = u
+			}
+			if e.Hash != "" && e.Duplicate == "" {
+				hashes[e.Path] = e.Hash
+			}
+			if _, err := fs.Stat(fsys, e.Path); err != nil {
+				issue(VerifyMissing, e.Path, e.URL)
+			}
+		}
+	}
+
+	// Извлечение ссылок:
+	parser := NewScanner()
+	parser.url = verifyBase
+	var page *url.URL
+	var file string
+	parser.visit = func(e Edge, u *url.URL) {
+		if u == nil || e.Attr == "text" {
+			return
+		}
+
+		// Ссылка на живой сайт:
+		if u.Scheme != verifyBase.Scheme || u.Host != verifyBase.Host {
+			if strings.EqualFold(u.Hostname(), origin.Hostname()) && (u.Scheme == "http" || u.Scheme == "https") {
+				issue(VerifyLive, file, u.String())
+			}
+			if u.Scheme == "file" && origin.Scheme == "file" && strings.HasPrefix(u.Path, origin.Path) {
+				issue(VerifyLive, file, u.String())
+			}
+			return
+		}
+
+		// Локальная ссылка:
+		ref := *u
+		ref.Scheme = ""
+		ref.Host = ""
+		target := page.ResolveReference(&ref)
+		target.Fragment = ""
+		r.Links++
+		if !verifyResolve(fsys, m, origin.Path, target) {
+			issue(VerifyMissing, file, target.RequestURI())
+		}
+	}
+
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		r.Files++
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			issue(VerifyMissing, name, "")
+			return nil
+		}
+		if len(data) == 0 {
+			issue(VerifyEmpty, name, "")
+		}
+		if h, ok := hashes[name]; ok && h != fmt.Sprintf("%x", sha256.Sum256(data)) {
+			issue(VerifyHash, name, "")
+		}
+
+		// Адрес страницы для разрешения относительных ссылок:
+		page = pages[name]
+		if page == nil {
+			page = origin.ResolveReference(&url.URL{Path: name})
+		}
+		file = name
+
+		// Документ на адресе-заглушке: ссылки, разрешённые от него, - локальные
+		local := *page
+		local.Scheme = verifyBase.Scheme
+		local.Host = verifyBase.Host
+		obj := &Source{url: &local}
+		switch strings.ToLower(path.Ext(name)) {
+		case ".html", ".htm", ".xhtml":
+			parser.readHTML(obj, data)
+		case ".css":
+			parser.readTXT(obj, data)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка чтения данных сайта: %w", err)
+	}
+
+	sort.SliceStable(r.Issues, func(i, j int) bool {
+		if r.Issues[i].Kind != r.Issues[j].Kind {
+			return r.Issues[i].Kind < r.Issues[j].Kind
+		}
+		return r.Issues[i].File < r.Issues[j].File
+	})
+
+	return r, nil
+}
+
+// Найти файл копии для локальной ссылки.
+// Сначала по манифесту, затем по пути - так же, как файлы
+// называются при сканировании: index.html, подбор расширения.
+// Параметр root - путь к корню сайта в URL, см.: Scanner.rootPath()
+func verifyResolve(fsys fs.FS, m *Manifest, root string, target *url.URL) bool {
+	if m != nil {
+		keys := []string{target.String()}
+		if target.Path == "/" && target.RawQuery == "" {
+			keys = append(keys, strings.TrimSuffix(target.String(), "/"))
+		}
+		for _, k := range keys {
+			if e := m.Lookup(k); e != nil && e.Path != "" {
+				_, err := fs.Stat(fsys, e.Path)
+				return err == nil
+			}
+		}
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+target.Path)+"/", root)
+	name = strings.TrimSuffix(name, "/")
+	candidates := []string{name, path.Join(name, "index.html"), name + ".html"}
+	if q := queryName(target.RawQuery); q != "" {
+		ext := path.Ext(name)
+		if name == "" || strings.HasSuffix(target.Path, "/") {
+			candidates = append(candidates, path.Join(name, "index@"+q+".html"))
+		} else if ext == "" {
+			candidates = append(candidates, name+"@"+q+".html")
+		} else {
+			candidates = append(candidates, strings.TrimSuffix(name, ext)+"@"+q+ext)
+		}
+	}
+
+	// Имена файлов, записанные сканером по политике имён файлов:
+	for _, c := range candidates {
+		candidates = append(candidates, fileSegments(c, FileNamesUTF8), fileSegments(c, FileNamesEscape))
+	}
+	for _, c := range candidates {
+		if c == "" || !fs.ValidPath(c) {
+			continue
+		}
+		if info, err := fs.Stat(fsys, c); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
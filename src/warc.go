@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Расширение файла WARC архива.
+// Каждая запись архива сжата отдельным gzip потоком.
+const WARC_EXT = ".warc.gz"
+
+// Профиль записей revisit для повторяющегося содержимого.
+const WARC_PROFILE_IDENTICAL = "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest"
+
+// Запись WARC архива
+type warcRecord struct {
+	Type        string      // Тип записи: "warcinfo", "request", "response", ...
+	ID          string      // Идентификатор записи: "<urn:uuid:...>"
+	Date        time.Time   // Дата захвата
+	URI         string      // Адрес ресурса. Пустой для "warcinfo"
+	ContentType string      // Тип содержимого блока записи
+	Headers     [][2]string // Дополнительные заголовки записи
+	Block       []byte      // Блок записи
+}
+
+// Первый захват содержимого.
+// Используется для записей revisit.
+type warcOrigin struct {
+	id   string
+	uri  string
+	date time.Time
+}
+
+// Писатель WARC 1.1 архива с индексом CDX.
+//
+// Записи пишутся по мере обработки ресурсов, индекс сохраняется
+// при закрытии архива рядом с ним: <name>.cdx
+type warcWriter struct {
+	mu      sync.Mutex
+	file    *os.File
+	offset  int64                 // Смещение следующей записи в файле
+	origins map[string]warcOrigin // Первые захваты по хешу содержимого
+	cdx     []string              // Строки индекса CDX
+}
+
+// Создать WARC архив в ранее открытом файле.
+// Первой записью пишется информация об архиве: warcinfo
+func newWARCWriter(f *os.File) (*warcWriter, error) {
+	w := &warcWriter{
+		file:    f,
+		origins: make(map[string]warcOrigin),
+	}
+
+	info := "software: " + APP_NAME + "/" + VERSION + "\r\n" +
+		"format: WARC File Format 1.1\r\n" +
+		"conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n"
+	_, _, err := w.write(&warcRecord{
+		Type:        "warcinfo",
+		Date:        time.Now(),
+		ContentType: "application/warc-fields",
+		Headers:     [][2]string{{"WARC-Filename", filepath.Base(f.Name())}},
+		Block:       []byte(info),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Записать обмен с сервером: запрос и ответ.
+//
+// Тело ответа payload передаётся в том виде, в котором оно получено
+// по сети (До распаковки). Если такое же тело уже было записано,
+// вместо ответа пишется запись revisit со ссылкой на первый захват.
+// Возвращает идентификатор записи ответа.
+func (w *warcWriter) WriteExchange(resp *http.Response, payload []byte, date time.Time) (string, error) {
+	uri := resp.Request.URL.String()
+
+	// Запрос:
+	reqBlock := warcRequest(resp.Request)
+
+	// Ответ:
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "HTTP/%d.%d %v\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	resp.Header.Write(&head)
+	head.WriteString("\r\n")
+	digest := warcDigest(payload)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	res := &warcRecord{
+		Type:        "response",
+		ID:          warcID(),
+		Date:        date,
+		URI:         uri,
+		ContentType: "application/http;msgtype=response",
+		Headers:     [][2]string{{"WARC-Payload-Digest", digest}},
+	}
+	origin, revisit := w.origins[digest]
+	if revisit {
+		res.Type = "revisit"
+		res.Headers = append(res.Headers,
+			[2]string{"WARC-Profile", WARC_PROFILE_IDENTICAL},
+			[2]string{"WARC-Refers-To", origin.id},
+			[2]string{"WARC-Refers-To-Target-URI", origin.uri},
+			[2]string{"WARC-Refers-To-Date", origin.date.UTC().Format(time.RFC3339)},
+		)
+		res.Block = head.Bytes()
+	} else {
+		w.origins[digest] = warcOrigin{id: res.ID, uri: uri, date: date}
+		res.Block = append(head.Bytes(), payload...)
+	}
+
+	if _, _, err := w.write(&warcRecord{
+		Type:        "request",
+		Date:        date,
+		URI:         uri,
+		ContentType: "application/http;msgtype=request",
+		Headers:     [][2]string{{"WARC-Concurrent-To", res.ID}},
+		Block:       reqBlock,
+	}); err != nil {
+		return "", err
+	}
+	offset, length, err := w.write(res)
+	if err != nil {
+		return "", err
+	}
+
+	// Индекс:
+	mim := "warc/revisit"
+	if !revisit {
+		mim = resp.Header.Get("Content-Type")
+		if i := strings.IndexByte(mim, ';'); i != -1 {
+			mim = mim[:i]
+		}
+		mim = strings.TrimSpace(mim)
+	}
+	redirect := resp.Header.Get("Location")
+	if mim == "" {
+		mim = "-"
+	}
+	if redirect == "" {
+		redirect = "-"
+	}
+	w.cdx = append(w.cdx, strings.Join([]string{
+		surt(resp.Request.URL),
+		date.UTC().Format("20060102150405"),
+		uri,
+		strings.ReplaceAll(mim, " ", ""),
+		fmt.Sprint(resp.StatusCode),
+		strings.TrimPrefix(digest, "sha1:"),
+		strings.ReplaceAll(redirect, " ", "%20"),
+		"-",
+		fmt.Sprint(length),
+		fmt.Sprint(offset),
+		filepath.Base(w.file.Name()),
+	}, " "))
+
+	return res.ID, nil
+}
+
+// Получить блок записи запроса: строка запроса и заголовки.
+// Собирается из полей запроса без обращения к транспорту HTTP,
+// поэтому подходит для запросов любого протокола: ftp://, file://, ...
+func warcRequest(req *http.Request) []byte {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%v %v HTTP/1.1\r\n", method, req.URL.RequestURI())
+	if host != "" {
+		fmt.Fprintf(&b, "Host: %v\r\n", host)
+	}
+	req.Header.WriteSubset(&b, map[string]bool{"Host": true})
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// Записать метаданные ресурса: поля в формате "имя: значение".
+// Запись связывается с записью ответа через concurrentTo.
+func (w *warcWriter) WriteMetadata(uri string, concurrentTo string, date time.Time, fields [][2]string) error {
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(f[0] + ": " + f[1] + "\r\n")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, _, err := w.write(&warcRecord{
+		Type:        "metadata",
+		Date:        date,
+		URI:         uri,
+		ContentType: "application/warc-fields",
+		Headers:     [][2]string{{"WARC-Concurrent-To", concurrentTo}},
+		Block:       []byte(b.String()),
+	})
+	return err
+}
+
+// Сохранить индекс CDX и закрыть архив.
+func (w *warcWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	sort.Strings(w.cdx)
+	data := " CDX N b a m s k r M S V g\n" + strings.Join(w.cdx, "\n")
+	if len(w.cdx) > 0 {
+		data += "\n"
+	}
+	path := strings.TrimSuffix(w.file.Name(), WARC_EXT) + ".cdx"
+	if err := writeFileAtomic(path, []byte(data), 0777); err != nil {
+		return fmt.Errorf("Не удалось записать индекс CDX: \"%v\": %w", path, err)
+	}
+
+	return nil
+}
+
+// Записать запись в архив отдельным gzip потоком.
+// Возвращает смещение и сжатый размер записи для индекса.
+// п.с. Писатель с Lock()
+func (w *warcWriter) write(rec *warcRecord) (int64, int64, error) {
+	if rec.ID == "" {
+		rec.ID = warcID()
+	}
+
+	var b bytes.Buffer
+	z := gzip.NewWriter(&b)
+	fmt.Fprintf(z, "WARC/1.1\r\n")
+	fmt.Fprintf(z, "WARC-Type: %v\r\n", rec.Type)
+	fmt.Fprintf(z, "WARC-Record-ID: %v\r\n", rec.ID)
+	fmt.Fprintf(z, "WARC-Date: %v\r\n", rec.Date.UTC().Format(time.RFC3339))
+	if rec.URI != "" {
+		fmt.Fprintf(z, "WARC-Target-URI: %v\r\n", rec.URI)
+	}
+	for _, h := range rec.Headers {
+		fmt.Fprintf(z, "%v: %v\r\n", h[0], h[1])
+	}
+	if rec.Type != "warcinfo" {
+		fmt.Fprintf(z, "WARC-Block-Digest: %v\r\n", warcDigest(rec.Block))
+	}
+	fmt.Fprintf(z, "Content-Type: %v\r\n", rec.ContentType)
+	fmt.Fprintf(z, "Content-Length: %d\r\n\r\n", len(rec.Block))
+	z.Write(rec.Block)
+	io.WriteString(z, "\r\n\r\n")
+	if err := z.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	offset := w.offset
+	n, err := w.file.Write(b.Bytes())
+	w.offset += int64(n)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Ошибка записи в WARC архив: %w", err)
+	}
+
+	return offset, int64(n), nil
+}
+
+// Сгенерировать идентификатор записи: "<urn:uuid:...>"
+func warcID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0F | 0x40 // Версия 4
+	u[8] = u[8]&0x3F | 0x80 // Вариант RFC 4122
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// Получить хеш данных в формате WARC: "sha1:<base32>"
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// Получить ключ адреса для индекса CDX в формате SURT:
+// "http://www.site.ru/a?b" -> "ru,site)/a?b"
+// IP адреса не переворачиваются.
+func surt(u *url.URL) string {
+	key := strings.ToLower(u.Hostname())
+	if net.ParseIP(key) == nil {
+		parts := strings.Split(strings.TrimPrefix(key, "www."), ".")
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+		key = strings.Join(parts, ",")
+	}
+
+	if p := u.Port(); p != "" && !(u.Scheme == "http" && p == "80") && !(u.Scheme == "https" && p == "443") {
+		key += ":" + p
+	}
+	key += ")" + strings.ToLower(u.EscapedPath())
+	if u.RawQuery != "" {
+		key += "?" + strings.ToLower(u.RawQuery)
+	}
+	if !strings.Contains(key, ")/") {
+		key = strings.Replace(key, ")", ")/", 1)
+	}
+
+	return key
+}
+
+// Получить описание найденной ссылки для метаданных: "<url> a/@href"
+func warcOutlink(e Edge, u *url.URL) string {
+	if e.Tag == "" {
+		return u.String() + " " + e.Attr
+	}
+	return u.String() + " " + e.Tag + "/@" + e.Attr
+}
+
+// Записать обмен с сервером в WARC архив, если он включен.
+// Ошибки записи не прерывают обработку ресурса и пишутся в журнал.
+// Возвращает идентификатор записи ответа или пустую строку.
+func (s *Scanner) archive(obj *Source, resp *http.Response, payload []byte, date time.Time) string {
+	if s.warc == nil {
+		return ""
+	}
+
+	id, err := s.warc.WriteExchange(resp, payload, date)
+	if err != nil {
+		s.logSource(slog.LevelError, obj, "Не удалось записать ресурс в WARC архив", "err", err)
+		return ""
+	}
+
+	return id
+}
+
+// Записать метаданные ресурса в WARC архив: источник и найденные ссылки.
+func (s *Scanner) archiveMeta(obj *Source, id string, date time.Time) {
+	if s.warc == nil || id == "" {
+		return
+	}
+
+	var fields [][2]string
+	if ref := obj.Referrer(); ref != nil {
+		fields = append(fields, [2]string{"via", ref.String()})
+	}
+	fields = append(fields, [2]string{"depth", fmt.Sprint(obj.Depth())})
+	obj.mu.RLock()
+	for _, link := range obj.links {
+		fields = append(fields, [2]string{"outlink", link})
+	}
+	obj.mu.RUnlock()
+
+	if err := s.warc.WriteMetadata(obj.url.String(), id, date, fields); err != nil {
+		s.logSource(slog.LevelError, obj, "Не удалось записать метаданные ресурса в WARC архив", "err", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Прочитать все записи WARC архива одной строкой.
+func readWARC(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWARCMemorySite(t *testing.T) {
+	f := NewMemoryFetcher().
+		Add("http://mem.test/", "text/html", `<!DOCTYPE html><a href="/a.html?x=1">a</a>`).
+		Add("http://mem.test/a.html?x=1", "text/html", `<!DOCTYPE html><p>a</p>`)
+
+	s := runScanner(t, ScannerParams{
+		URL:          "http://mem.test/",
+		OutputFormat: OutputWARC,
+		Fetchers:     map[string]Fetcher{"http": f},
+	})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+
+	v := readWARC(t, s.Dir())
+	for _, want := range []string{
+		"WARC-Type: request\r\n",
+		"WARC-Target-URI: http://mem.test/a.html?x=1\r\n",
+		"GET /a.html?x=1 HTTP/1.1\r\nHost: mem.test\r\n",
+		"HTTP/1.1 200 OK\r\n",
+		"<p>a</p>",
+	} {
+		if !strings.Contains(v, want) {
+			t.Errorf("в архиве нет %q", want)
+		}
+	}
+
+	cdx, err := os.ReadFile(strings.TrimSuffix(s.Dir(), WARC_EXT) + ".cdx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(cdx), "test,mem)/a.html?x=1 ") {
+		t.Errorf("индекс CDX: %q", cdx)
+	}
+}
+
+func TestWARCLocalFolder(t *testing.T) {
+	site := filepath.Join(t.TempDir(), "site")
+	os.MkdirAll(site, 0777)
+	if err := os.WriteFile(filepath.Join(site, "index.html"), []byte(`<!DOCTYPE html><a href="a.txt">a</a>`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(site, "a.txt"), []byte("text"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	s := runScanner(t, ScannerParams{URL: site, WARC: true})
+	if s.State() != ScannerComplete {
+		t.Fatalf("State() = %v, err: %v", s.State(), s.Err())
+	}
+
+	root := filepath.ToSlash(site)
+	if !strings.HasPrefix(root, "/") {
+		root = "/" + root
+	}
+	v := readWARC(t, s.Dir()+WARC_EXT)
+	for _, want := range []string{
+		"GET " + root + "/ HTTP/1.1\r\n",
+		"GET " + root + "/a.txt HTTP/1.1\r\n",
+		"WARC-Target-URI: file://" + root + "/a.txt\r\n",
+	} {
+		if !strings.Contains(v, want) {
+			t.Errorf("в архиве нет %q", want)
+		}
+	}
+}